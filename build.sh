@@ -21,6 +21,8 @@ cat commands/domains.sh >> $OUT && printf "\n" >> $OUT
 cat commands/backup.sh >> $OUT && printf "\n" >> $OUT
 cat commands/recover.sh >> $OUT && printf "\n" >> $OUT
 cat commands/stats.sh >> $OUT && printf "\n" >> $OUT
+cat commands/snapshots.sh >> $OUT && printf "\n" >> $OUT
+cat commands/status.sh >> $OUT && printf "\n" >> $OUT
 cat commands/fsd.sh >> $OUT && printf "\n" >> $OUT
 cat helpers/password.sh >> $OUT && printf "\n" >> $OUT
 cat helpers/validation.sh >> $OUT && printf "\n" >> $OUT
@@ -35,7 +37,7 @@ printf "compiling binaries... "
 
 go build -o ./build/dfb-progress-parser -i ./tools/progress-parser/cmd.go
 FYNE_FONT=/Applications/dfb.app/Contents/Resources/fonts/Lato-Black.ttf go build -o ./build/dfb-progress-parser-gui -i ./tools/progress-parser-gui/cmd.go
-go build -o ./build/dfb-stats -i ./tools/stats/cmd.go
+go build -o ./build/dfb-stats -i ./tools/stats
 go build -o ./build/dfb-fsd -i ./agents/fsd.go
 
 echo "done."
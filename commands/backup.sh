@@ -39,6 +39,14 @@ backup() {
         lock_dfb "backup"
     fi
 
+    # make sure the stats files exist and are written with the current
+    # schema before any rows are appended to them
+    if ! dfb-stats migrate "$group" > /dev/null; then
+        echo "failed to migrate stats files of $group"
+        unlock_dfb
+        exit 1
+    fi
+
     if [ "$confirm" = true ]; then
         confirm_backup_should_start $repo_name $group
     fi
@@ -71,7 +79,7 @@ backup() {
     echo -n "$password" \
     | restic -r "$repo_path" stats --mode raw-data --json \
     | ggrep "{" \
-    | jq -r '[.[]] | @csv' \
+    | jq -r '[.total_size, .total_file_count, .total_blob_count] | @csv' \
     | tr -d '\n' >> "$repo_raw_data_csv" \
     && echo ",$group,$repo_name,$(gdate +%Y-%m-%dT%H:%M:%S%z)" >> "$repo_raw_data_csv"
 
@@ -172,6 +180,7 @@ backup_domain() {
     snapshots_csv="$STATS_PATH/snapshots.csv"
     domain_restore_size_csv="$STATS_PATH/domain_restore_size.csv"
     domain_raw_data_csv="$STATS_PATH/domain_raw_data.csv"
+    record_changes_log="$STATS_PATH/record-changes.log"
     if [ ! -d "$STATS_PATH" ]; then
         mkdir "$STATS_PATH"
     fi
@@ -188,9 +197,16 @@ backup_domain() {
         2>&1 \
         | unbuffer -p tee >( \
             ggrep "summary" \
-            | jq -r 'select(.message_type=="summary") | [.[]] | @csv' \
+            | jq -r 'select(.message_type=="summary") | [
+                .snapshot_id, .files_new, .files_changed, .files_unmodified,
+                .dirs_new, .dirs_changed, .dirs_unmodified, .data_blobs, .tree_blobs,
+                .data_added, .total_files_processed, .total_bytes_processed, .total_duration
+            ] | @csv' \
             | tr -d '\n' >> "$snapshots_csv" \
-            && echo ",$group,$domain,$repo_name,$(gdate +%Y-%m-%dT%H:%M:%S%z)" >> "$snapshots_csv"
+            && echo ",$group,$domain,$repo_name,$(gdate +%Y-%m-%dT%H:%M:%S%z),live" >> "$snapshots_csv"
+        ) >( \
+            dfb-stats record-changes "$group" >> "$record_changes_log" 2>&1 \
+            || echo "$(gdate +%Y-%m-%dT%H:%M:%S%z) failed to record changes of $domain in $repo_name" >> "$record_changes_log"
         ) \
         | if [ "$gui" = true ]; \
             then \
@@ -206,16 +222,16 @@ backup_domain() {
     echo -n "$password" \
         | restic -r "$repo_path" stats latest --mode restore-size --json \
         | ggrep "{" \
-        | jq -r '[.[]] | @csv' \
+        | jq -r '[.total_size, .total_file_count] | @csv' \
         | tr -d '\n' >> "$domain_restore_size_csv" \
-        && echo ",$group,$domain,$repo_name,$(gdate +%Y-%m-%dT%H:%M:%S%z)" >> "$domain_restore_size_csv"
+        && echo ",$group,$domain,$repo_name,$(gdate +%Y-%m-%dT%H:%M:%S%z),,live" >> "$domain_restore_size_csv"
 
     echo -n "$password" \
         | restic -r "$repo_path" stats latest --mode raw-data --json \
         | ggrep "{" \
-        | jq -r '[.[]] | @csv' \
+        | jq -r '[.total_size, .total_file_count, .total_blob_count] | @csv' \
         | tr -d '\n' >> "$domain_raw_data_csv" \
-        && echo ",$group,$domain,$repo_name,$(gdate +%Y-%m-%dT%H:%M:%S%z)" >> "$domain_raw_data_csv"
+        && echo ",$group,$domain,$repo_name,$(gdate +%Y-%m-%dT%H:%M:%S%z),,live" >> "$domain_raw_data_csv"
 
     if [ "$gui" = true ]; then
         print_message_to_progress_file "$group" "$domain" "gathering_stats_done"
@@ -0,0 +1,47 @@
+#
+# Summary: Snapshots command
+#
+# The snapshots command lists the snapshots of a group in a
+# repo, optionally only the snapshots of a single domain. This
+# command is a wrapper around the tool written in go (see
+# tools/stats) that prompts for the password of the repo.
+
+snapshots() {
+    verify_env
+
+    for var in "$@"; do
+        if [[ "$var" =~ ^-h|--help$  ]]; then
+            print_snapshots_help
+            exit
+        fi
+    done
+
+    group=$2
+    validate_group $group
+    repo_name=$3
+    validate_repo $group $repo_name
+    repo_path=$(cat "$DFB_PATH/$group/repos/$repo_name")
+
+    promt_for_password $repo_name
+    verify_password $password $repo_path
+
+    echo -n "$password" | dfb-stats snapshots "${@:2}"
+}
+
+print_snapshots_help() {
+    cat <<HEREDOC
+List snapshots of a group.
+
+Lists the snapshots of a group in a repo with the stats recorded
+during backup, such as the data added by each snapshot.
+
+Usage:
+  ${PROGRAM} snapshots [group] [repo] [domain]
+
+Options:
+  -f --format   Output format, table or json (default table).
+  --from        Only list snapshots taken on or after date (YYYY-MM-DD).
+  --to          Only list snapshots taken on or before date (YYYY-MM-DD).
+  -h --help     Show this screen.
+HEREDOC
+}
@@ -6,13 +6,34 @@
 # around the tool written in go (see tools/stats) but
 # will try to open the output in quick look. Note, if a
 # non default output path is used no preview will be availible.
+# Where quick look is not availible the chart is left in place.
+# The backfill subcommand needs the password of the repo, which
+# is prompted for before the tool is run.
 
 stats() {
     verify_env
     default_output_path="/tmp/dfb-metric.png"
 
+    if [[ "$2" == "backfill" ]] && [[ ! "$*" =~ -h|--help ]]; then
+        group=$3
+        validate_group $group
+        repo_name=$4
+        validate_repo $group $repo_name
+        repo_path=$(cat "$DFB_PATH/$group/repos/$repo_name")
+
+        promt_for_password $repo_name
+        verify_password $password $repo_path
+
+        echo -n "$password" | dfb-stats "${@:2}"
+        exit
+    fi
+
     dfb-stats "${@:2}"
     if [ -f $default_output_path ]; then
+        if ! command -v qlmanage > /dev/null; then
+            echo "chart written to $default_output_path, use --format term to view it in the terminal"
+            return
+        fi
         qlmanage -p $default_output_path 2> /dev/null 1> /dev/null
         rm $default_output_path
     fi
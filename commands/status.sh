@@ -0,0 +1,48 @@
+#
+# Summary: Status command
+#
+# The status command reports how long ago each domain of a
+# group was backed up to each repo of the group. This command
+# is a wrapper around the tool written in go (see tools/stats),
+# the exit code of the tool is the exit code of the command so
+# that it can be used from cron or a monitoring system.
+
+status() {
+    verify_env
+
+    for var in "$@"; do
+        if [[ "$var" =~ ^-h|--help$  ]]; then
+            print_status_help
+            exit
+        fi
+    done
+
+    if [ "${2:-}" != "all" ]; then
+        validate_group $2
+    fi
+
+    dfb-stats status "${@:2}"
+}
+
+print_status_help() {
+    cat <<HEREDOC
+Report how long ago each domain was backed up.
+
+Lists the age of the last snapshot of every domain of a group in
+every repo of the group, and whether it is ok, a warning or critical.
+Thresholds can be set per domain in ~/.dfb/[group]/thresholds, with
+a line such as "photos 7d 30d", or "* 1d 3d" for the other domains.
+
+The exit code is 0 if all domains are ok, 1 if any is a warning,
+2 if any is critical and 3 if the status could not be checked.
+
+Usage:
+  ${PROGRAM} status [group|all]
+
+Options:
+  -f --format     Output format, table or json (default table).
+  -w --warning    Age of the last snapshot that is a warning, eg. 36h or 2d.
+  -c --critical   Age of the last snapshot that is critical, eg. 7d or 2w.
+  -h --help       Show this screen.
+HEREDOC
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"strings"
 
 	d "github.com/nattvara/dfb/internal/domains"
 	"github.com/nattvara/dfb/internal/paths"
@@ -31,6 +32,14 @@ func FetchGroups() []Group {
 	return groups
 }
 
+// Load returns the group with given name
+func Load(name string) Group {
+	return Group{
+		Name: name,
+		Path: fmt.Sprintf("%s/%s", paths.DFB(), name),
+	}
+}
+
 // NumberOfGroupsMounted counts number of mounted groups
 func NumberOfGroupsMounted(groups []Group) int {
 	count := 0
@@ -67,6 +76,15 @@ func (group *Group) IsMounted() bool {
 	return false
 }
 
+// RepoPath returns the path to the restic repo of the group with given name
+func (group *Group) RepoPath(repoName string) (string, error) {
+	data, err := ioutil.ReadFile(fmt.Sprintf("%s/repos/%s", group.Path, repoName))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
 // Domains returns the domains belonging to the group
 func (group *Group) Domains() []d.Domain {
 	files, err := ioutil.ReadDir(fmt.Sprintf("%s/domains", group.Path))
@@ -82,3 +100,18 @@ func (group *Group) Domains() []d.Domain {
 
 	return domains
 }
+
+// Repos returns the names of the repos of the group
+func (group *Group) Repos() []string {
+	files, err := ioutil.ReadDir(fmt.Sprintf("%s/repos", group.Path))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var repos []string
+	for _, file := range files {
+		repos = append(repos, file.Name())
+	}
+
+	return repos
+}
@@ -0,0 +1,54 @@
+package components
+
+import (
+	"fmt"
+
+	"fyne.io/fyne"
+	"fyne.io/fyne/layout"
+	"fyne.io/fyne/widget"
+)
+
+const (
+	// ErrorListLength is how many failed paths should be showing at any time
+	ErrorListLength = 5
+)
+
+// Errors is a component with the number of errors during backup and the
+// most recent paths that failed
+type Errors struct {
+	container *fyne.Container
+
+	count  *widget.Label
+	lines  []*widget.Label
+	failed []string
+}
+
+// GetNewContainer returns a fyne container with the necessary widgets
+// to display errors
+func (c *Errors) GetNewContainer() *fyne.Container {
+	c.count = widget.NewLabel("Errors: 0")
+	c.container = fyne.NewContainerWithLayout(
+		layout.NewVBoxLayout(),
+		c.count,
+	)
+	for i := 0; i < ErrorListLength; i++ {
+		line := widget.NewLabel("")
+		c.lines = append(c.lines, line)
+		c.container.AddObject(line)
+	}
+	return c.container
+}
+
+// AddError adds a failed path of given domain to component c
+func (c *Errors) AddError(domain string, path string, msg string) {
+	c.failed = append(c.failed, fmt.Sprintf("%s: %s (%s)", domain, path, msg))
+	c.count.SetText(fmt.Sprintf("Errors: %v", len(c.failed)))
+
+	start := len(c.failed) - ErrorListLength
+	if start < 0 {
+		start = 0
+	}
+	for i, failed := range c.failed[start:] {
+		c.lines[i].SetText(truncateString(failed, 100))
+	}
+}
@@ -9,20 +9,23 @@ type MessageReceiver struct {
 	Report *Report
 }
 
-// ListenForMessages will listen for restic messages on given channel
-func (receiver *MessageReceiver) ListenForMessages(channel chan restic.Message) {
+// ListenForMessages will listen for restic events and decode errors on given channels
+func (receiver *MessageReceiver) ListenForMessages(events chan *restic.Event, errors chan error) {
 	for {
-		msg := <-channel
-		switch msg.Type {
-		case "dfb":
-			dfb := restic.DFBMessageFromString(msg.Body)
-			receiver.handleDFBMessage(dfb)
-		case "status":
-			status := restic.StatusMessageFromString(msg.Body)
-			receiver.handleStatusMessage(status)
-		case "summary":
-			summary := restic.SummaryMessageFromString(msg.Body)
-			receiver.handleSummaryMessage(summary)
+		select {
+		case event := <-events:
+			switch event.Type {
+			case "dfb":
+				receiver.handleDFBMessage(*event.DFB)
+			case "status":
+				receiver.handleStatusMessage(*event.Status)
+			case "summary":
+				receiver.handleSummaryMessage(*event.Summary)
+			case "error":
+				receiver.Report.AddError(*event.Error)
+			}
+		case err := <-errors:
+			receiver.handleDecodeError(err)
 		}
 	}
 }
@@ -41,6 +44,10 @@ func (receiver *MessageReceiver) handleSummaryMessage(msg restic.SummaryMessage)
 	receiver.Report.CompleteCurrentDomain(msg)
 }
 
+func (receiver *MessageReceiver) handleDecodeError(err error) {
+	receiver.Report.StatusComponent.SetSecondStatusLine("failed to read restic output, " + err.Error())
+}
+
 func (receiver *MessageReceiver) handleDFBMessage(msg restic.DFBMessage) {
 	switch msg.Action {
 	case "begin":
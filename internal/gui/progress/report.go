@@ -27,6 +27,7 @@ type Report struct {
 	MessageReceiver *MessageReceiver
 
 	StatusComponent    *components.Status
+	ErrorsComponent    *components.Errors
 	CompletedComponent *components.Completed
 	BottomComponent    *components.Bottom
 
@@ -40,11 +41,13 @@ func (report *Report) LoadUI(app fyne.App) {
 	report.window = app.NewWindow("Progress report for dfb backup started at " + now)
 
 	report.StatusComponent = &components.Status{}
+	report.ErrorsComponent = &components.Errors{}
 	report.CompletedComponent = &components.Completed{}
 	report.BottomComponent = &components.Bottom{App: report.app}
 
 	content := fyne.NewContainerWithLayout(layout.NewVBoxLayout())
 	content.AddObject(report.StatusComponent.GetNewContainer())
+	content.AddObject(report.ErrorsComponent.GetNewContainer())
 	content.AddObject(report.CompletedComponent.GetNewContainer())
 	content.AddObject(report.BottomComponent.GetNewContainer())
 	report.window.SetContent(content)
@@ -78,6 +81,15 @@ func (report *Report) CompleteCurrentDomain(msg restic.SummaryMessage) {
 	)
 }
 
+// AddError adds an error restic reported during the backup of the last started domain
+func (report *Report) AddError(msg restic.ErrorMessage) {
+	var domain string
+	if len(report.domains) > 0 {
+		domain = report.domains[len(report.domains)-1].Name
+	}
+	report.ErrorsComponent.AddError(domain, msg.Item, msg.GetErrorString())
+}
+
 // CompleteUnavailibleDomain completes the snapshot of a domain that was unavailable
 func (report *Report) CompleteUnavailibleDomain(group string, domain string, msg string) {
 	report.CompletedComponent.AddCompletedDomain(
@@ -0,0 +1,373 @@
+package restic
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultBinary is the restic binary used by a Client unless another is set
+	DefaultBinary = "restic"
+
+	// StatsModeRawData is the stats mode that counts the data stored in the repository
+	StatsModeRawData = "raw-data"
+
+	// StatsModeRestoreSize is the stats mode that counts the size of a restore
+	StatsModeRestoreSize = "restore-size"
+)
+
+// Client runs restic commands against a repository
+type Client struct {
+	Binary   string
+	Repo     string
+	Password PasswordSource
+}
+
+// NewClient returns a new client for the repository at given path
+func NewClient(repo string, password PasswordSource) *Client {
+	return &Client{
+		Binary:   DefaultBinary,
+		Repo:     repo,
+		Password: password,
+	}
+}
+
+// CommandError is returned by Client when restic exits with an error
+type CommandError struct {
+	Args     []string
+	ExitCode int
+	Stderr   string
+}
+
+// Error returns a string representation of CommandError e
+func (e *CommandError) Error() string {
+	return fmt.Sprintf(
+		"restic %s failed with exit code %v: %s",
+		strings.Join(e.Args, " "),
+		e.ExitCode,
+		strings.TrimSpace(e.Stderr),
+	)
+}
+
+// Snapshot should match a snapshot listed by restic snapshots --json
+type Snapshot struct {
+	ID       string    `json:"id"`
+	ShortID  string    `json:"short_id"`
+	Time     time.Time `json:"time"`
+	Parent   string    `json:"parent"`
+	Tree     string    `json:"tree"`
+	Paths    []string  `json:"paths"`
+	Hostname string    `json:"hostname"`
+	Username string    `json:"username"`
+	Tags     []string  `json:"tags"`
+}
+
+// HasTag returns whether snapshot s is tagged with given tag
+func (s *Snapshot) HasTag(tag string) bool {
+	for _, t := range s.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Stats should match the output of restic stats --json
+type Stats struct {
+	TotalSize      int64 `json:"total_size"`
+	TotalFileCount int   `json:"total_file_count"`
+	TotalBlobCount int   `json:"total_blob_count"`
+}
+
+// ForgetGroup should match a group of snapshots in the output of restic forget --json
+type ForgetGroup struct {
+	Tags   []string   `json:"tags"`
+	Host   string     `json:"host"`
+	Paths  []string   `json:"paths"`
+	Keep   []Snapshot `json:"keep"`
+	Remove []Snapshot `json:"remove"`
+}
+
+// BackupOptions are the options for Client.Backup
+type BackupOptions struct {
+	Dir         string
+	Tags        []string
+	ExcludeFile string
+}
+
+// ForgetOptions are the options for Client.Forget
+type ForgetOptions struct {
+	Tags        []string
+	KeepLast    int
+	KeepDaily   int
+	KeepWeekly  int
+	KeepMonthly int
+	KeepYearly  int
+	Prune       bool
+}
+
+// Backup backs up given path and calls handle with every event restic emits,
+// on stdout and on stderr where restic writes errors. Lines that cannot be
+// decoded are passed to handle as errors. Returns the summary of the snapshot
+func (c *Client) Backup(path string, opts BackupOptions, handle func(*Event, error)) (*SummaryMessage, error) {
+	args := []string{"backup", path, "--verbose", "--json"}
+	for _, tag := range opts.Tags {
+		args = append(args, "--tag", tag)
+	}
+	if opts.ExcludeFile != "" {
+		args = append(args, "--exclude-file", opts.ExcludeFile)
+	}
+
+	cmd, stderr, err := c.command(args...)
+	if err != nil {
+		return nil, err
+	}
+	cmd.Dir = opts.Dir
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stderr = nil
+	stderrPipe, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	var summary *SummaryMessage
+	for decoded := range decodeAll(stdout, io.TeeReader(stderrPipe, stderr)) {
+		if decoded.event != nil && decoded.event.Type == "summary" {
+			summary = decoded.event.Summary
+		}
+		if handle != nil {
+			handle(decoded.event, decoded.err)
+		}
+	}
+
+	if err := c.wait(cmd, args, stderr); err != nil {
+		return summary, err
+	}
+	if summary == nil {
+		return nil, errors.New("restic backup completed without a summary")
+	}
+	return summary, nil
+}
+
+// decodedEvent is an event, or the error decoding it, read by decodeAll
+type decodedEvent struct {
+	event *Event
+	err   error
+}
+
+// decodeAll decodes the events of all given readers concurrently, the returned
+// channel is closed once every reader is read to its end
+func decodeAll(readers ...io.Reader) <-chan decodedEvent {
+	events := make(chan decodedEvent)
+
+	var wg sync.WaitGroup
+	for _, r := range readers {
+		wg.Add(1)
+		go func(r io.Reader) {
+			defer wg.Done()
+			decoder := NewDecoder(r)
+			for {
+				event, err := decoder.Decode()
+				if err == io.EOF {
+					return
+				}
+				events <- decodedEvent{event: event, err: err}
+			}
+		}(r)
+	}
+
+	go func() {
+		wg.Wait()
+		close(events)
+	}()
+	return events
+}
+
+// Snapshots returns the snapshots in the repository, if tags are given
+// only snapshots with any of the tags are returned
+func (c *Client) Snapshots(tags ...string) ([]Snapshot, error) {
+	var snapshots []Snapshot
+
+	args := []string{"snapshots", "--json"}
+	for _, tag := range tags {
+		args = append(args, "--tag", tag)
+	}
+
+	out, err := c.output(args...)
+	if err != nil {
+		return snapshots, err
+	}
+
+	err = decodeJSON(out, &snapshots)
+	return snapshots, err
+}
+
+// Stats returns stats in given mode for given snapshots, or for the
+// whole repository if no snapshots are given
+func (c *Client) Stats(mode string, snapshotIDs ...string) (*Stats, error) {
+	args := []string{"stats"}
+	args = append(args, snapshotIDs...)
+	args = append(args, "--mode", mode, "--json")
+
+	out, err := c.output(args...)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &Stats{}
+	err = decodeJSON(out, stats)
+	return stats, err
+}
+
+// Forget removes snapshots according to the policy in given options,
+// returns the snapshots that were kept and removed
+func (c *Client) Forget(opts ForgetOptions) ([]ForgetGroup, error) {
+	var groups []ForgetGroup
+
+	args := []string{"forget", "--json"}
+	for _, tag := range opts.Tags {
+		args = append(args, "--tag", tag)
+	}
+	policy := []struct {
+		flag  string
+		value int
+	}{
+		{"--keep-last", opts.KeepLast},
+		{"--keep-daily", opts.KeepDaily},
+		{"--keep-weekly", opts.KeepWeekly},
+		{"--keep-monthly", opts.KeepMonthly},
+		{"--keep-yearly", opts.KeepYearly},
+	}
+	for _, keep := range policy {
+		if keep.value > 0 {
+			args = append(args, keep.flag, strconv.Itoa(keep.value))
+		}
+	}
+	if opts.Prune {
+		args = append(args, "--prune")
+	}
+
+	out, err := c.output(args...)
+	if err != nil {
+		return groups, err
+	}
+
+	err = decodeJSON(out, &groups)
+	return groups, err
+}
+
+// Check checks the repository for errors, use readData to also verify the
+// data of all packs
+func (c *Client) Check(readData bool) error {
+	args := []string{"check"}
+	if readData {
+		args = append(args, "--read-data")
+	}
+	_, err := c.output(args...)
+	return err
+}
+
+// Mount mounts the repository at given mountpoint, blocks until the
+// repository is unmounted
+func (c *Client) Mount(mountpoint string) error {
+	args := []string{"mount", mountpoint}
+	cmd, stderr, err := c.command(args...)
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return c.wait(cmd, args, stderr)
+}
+
+// Restore restores given snapshot to target, if include paths are given
+// only those are restored
+func (c *Client) Restore(snapshotID string, target string, include ...string) error {
+	args := []string{"restore", snapshotID, "--target", target}
+	for _, path := range include {
+		args = append(args, "--include", path)
+	}
+	_, err := c.output(args...)
+	return err
+}
+
+// command returns a command for restic with given args against the repository
+// of Client c, the password is written to stdin of the command the same way
+// as the dfb commands do it
+func (c *Client) command(args ...string) (*exec.Cmd, *bytes.Buffer, error) {
+	password, err := c.Password.Password()
+	if err != nil {
+		return nil, nil, errors.New("failed to get password. " + err.Error())
+	}
+
+	binary := c.Binary
+	if binary == "" {
+		binary = DefaultBinary
+	}
+
+	cmd := exec.Command(binary, append([]string{"-r", c.Repo}, args...)...)
+	cmd.Stdin = strings.NewReader(password)
+
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	return cmd, stderr, nil
+}
+
+// output runs restic with given args and returns what was written to stdout
+func (c *Client) output(args ...string) ([]byte, error) {
+	cmd, stderr, err := c.command(args...)
+	if err != nil {
+		return nil, err
+	}
+
+	stdout := &bytes.Buffer{}
+	cmd.Stdout = stdout
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	err = c.wait(cmd, args, stderr)
+	return stdout.Bytes(), err
+}
+
+// wait waits for cmd to exit and returns a *CommandError if it failed
+func (c *Client) wait(cmd *exec.Cmd, args []string, stderr *bytes.Buffer) error {
+	err := cmd.Wait()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return &CommandError{
+			Args:     args,
+			ExitCode: exitErr.ExitCode(),
+			Stderr:   stderr.String(),
+		}
+	}
+	return err
+}
+
+// decodeJSON decodes the first line of json in output from restic into v,
+// restic may print other lines of text around the json
+func decodeJSON(output []byte, v interface{}) error {
+	for _, line := range bytes.Split(output, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 || (line[0] != '{' && line[0] != '[') {
+			continue
+		}
+		return json.Unmarshal(line, v)
+	}
+	return errors.New("restic did not output any json")
+}
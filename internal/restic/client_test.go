@@ -0,0 +1,164 @@
+package restic
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newStubClient returns a client that runs a shell script with given body in
+// place of restic, and the directory the script records the arguments it was
+// run with and its stdin to, in the files args and stdin
+func newStubClient(t *testing.T, body string) (*Client, string) {
+	dir, err := ioutil.TempDir("", "dfb-restic-stub")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	script := "#!/bin/sh\n" +
+		"printf '%s\\n' \"$@\" > " + dir + "/args\n" +
+		"cat > " + dir + "/stdin\n" +
+		body + "\n"
+	binary := filepath.Join(dir, "restic")
+	if err := ioutil.WriteFile(binary, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	client := NewClient("/backups/repo", StaticPassword("secret"))
+	client.Binary = binary
+	return client, dir
+}
+
+// readStubFile returns the content of file with given name recorded by the stub in dir
+func readStubFile(t *testing.T, dir string, name string) string {
+	content, err := ioutil.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestClientBackup(t *testing.T) {
+	client, dir := newStubClient(t, `
+echo '{"message_type":"status","percent_done":0.5}'
+echo '{"message_type":"error","error":"permission denied","during":"archival","item":"/data/secret"}' >&2
+echo '{"message_type":"summary","data_added":42,"snapshot_id":"3f2c8a91"}'
+`)
+	defer os.RemoveAll(dir)
+
+	var types []string
+	var errorItems []string
+	summary, err := client.Backup("/data", BackupOptions{Tags: []string{"docs"}}, func(event *Event, err error) {
+		if err != nil {
+			t.Errorf("got decode error %v", err)
+			return
+		}
+		types = append(types, event.Type)
+		if event.Error != nil {
+			errorItems = append(errorItems, event.Error.Item)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if summary == nil || summary.SnapshotID != "3f2c8a91" || summary.DataAdded != 42 {
+		t.Errorf("got summary %+v, want snapshot 3f2c8a91 with 42 bytes added", summary)
+	}
+	if len(types) != 3 {
+		t.Errorf("got events %v, want status, error and summary", types)
+	}
+	if !reflect.DeepEqual(errorItems, []string{"/data/secret"}) {
+		t.Errorf("got errors for %v, want the error written to stderr for /data/secret", errorItems)
+	}
+
+	args := strings.Fields(readStubFile(t, dir, "args"))
+	want := []string{"-r", "/backups/repo", "backup", "/data", "--verbose", "--json", "--tag", "docs"}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("got args %v, want %v", args, want)
+	}
+}
+
+func TestClientBackupFails(t *testing.T) {
+	client, dir := newStubClient(t, `
+echo 'Fatal: unable to open repository' >&2
+exit 1
+`)
+	defer os.RemoveAll(dir)
+
+	var decodeErrors int
+	_, err := client.Backup("/data", BackupOptions{}, func(event *Event, err error) {
+		if err != nil {
+			decodeErrors++
+		}
+	})
+
+	commandErr, ok := err.(*CommandError)
+	if !ok {
+		t.Fatalf("got %v, want a *CommandError", err)
+	}
+	if commandErr.ExitCode != 1 || !strings.Contains(commandErr.Stderr, "unable to open repository") {
+		t.Errorf("got exit code %v and stderr %q, want 1 and the fatal error", commandErr.ExitCode, commandErr.Stderr)
+	}
+	if decodeErrors != 1 {
+		t.Errorf("got %v decode errors, want the fatal error as 1", decodeErrors)
+	}
+}
+
+func TestClientSnapshots(t *testing.T) {
+	client, dir := newStubClient(t, `
+echo 'repository 1a2b3c4d opened successfully'
+echo '[{"id":"3f2c8a91d4e5","short_id":"3f2c8a91","tags":["docs"]},{"id":"9b7d1e04c5a2","short_id":"9b7d1e04","tags":["docs","weekly"]}]'
+`)
+	defer os.RemoveAll(dir)
+
+	snapshots, err := client.Snapshots("docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(snapshots) != 2 || snapshots[0].ShortID != "3f2c8a91" || !snapshots[1].HasTag("weekly") {
+		t.Errorf("got snapshots %+v, want 3f2c8a91 and 9b7d1e04", snapshots)
+	}
+
+	args := strings.Fields(readStubFile(t, dir, "args"))
+	want := []string{"-r", "/backups/repo", "snapshots", "--json", "--tag", "docs"}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("got args %v, want %v", args, want)
+	}
+}
+
+func TestClientPasswordSources(t *testing.T) {
+	client, dir := newStubClient(t, `echo '[]'`)
+	defer os.RemoveAll(dir)
+
+	os.Setenv("DFB_TEST_PASSWORD", "from env")
+	defer os.Unsetenv("DFB_TEST_PASSWORD")
+
+	tests := map[string]PasswordSource{
+		"static":      StaticPassword("static"),
+		"from env":    EnvPassword("DFB_TEST_PASSWORD"),
+		"from reader": &ReaderPassword{Reader: strings.NewReader("from reader\nnext line\n")},
+	}
+	for want, password := range tests {
+		client.Password = password
+		if _, err := client.Snapshots(); err != nil {
+			t.Fatal(err)
+		}
+		if got := readStubFile(t, dir, "stdin"); got != want {
+			t.Errorf("got password %q on stdin, want %q", got, want)
+		}
+	}
+
+	os.Remove(filepath.Join(dir, "args"))
+	client.Password = EnvPassword("DFB_TEST_PASSWORD_UNSET")
+	if _, err := client.Snapshots(); err == nil {
+		t.Error("got no error for an unset password, want one")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "args")); err == nil {
+		t.Error("restic was run without a password")
+	}
+}
@@ -0,0 +1,152 @@
+package restic
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+const (
+	// maxLineLength is the longest line of json the decoder will read
+	maxLineLength = 1024 * 1024
+)
+
+// Event is a message read from the json output of restic, or a dfb message,
+// the field matching Type is set to the decoded message
+type Event struct {
+	Line int
+	Type string
+	Raw  []byte
+
+	Status        *StatusMessage
+	Summary       *SummaryMessage
+	Error         *ErrorMessage
+	VerboseStatus *VerboseStatusMessage
+	DFB           *DFBMessage
+}
+
+// DecodeError is returned by Decoder when a line could not be decoded into an Event
+type DecodeError struct {
+	Line int
+	Raw  []byte
+	Err  error
+}
+
+// Error returns a string representation of DecodeError e
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("line %v: %s", e.Line, e.Err.Error())
+}
+
+// Decoder reads restic json messages from an input stream, one message per line
+type Decoder struct {
+	reader *bufio.Reader
+	line   int
+	failed bool
+}
+
+// NewDecoder returns a new decoder that reads from r
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{reader: bufio.NewReaderSize(r, 64*1024)}
+}
+
+// Decode reads the next message from the input of Decoder d. Empty lines are
+// skipped. If a line cannot be decoded, or is longer than maxLineLength, a
+// *DecodeError is returned, and the next call to Decode continues with the
+// following line. io.EOF is returned when there is no more input, or after an
+// error reading the input was returned
+func (d *Decoder) Decode() (*Event, error) {
+	for {
+		raw, err := d.readLine()
+		if err != nil {
+			return nil, err
+		}
+		raw = bytes.TrimSpace(raw)
+		if len(raw) == 0 {
+			continue
+		}
+
+		event, err := decodeEvent(raw)
+		if err != nil {
+			return nil, &DecodeError{Line: d.line, Raw: raw, Err: err}
+		}
+		event.Line = d.line
+		return event, nil
+	}
+}
+
+// readLine reads the next line from the input of Decoder d. A line longer than
+// maxLineLength is read to its end and returned as a *DecodeError, so that the
+// input keeps being consumed
+func (d *Decoder) readLine() ([]byte, error) {
+	if d.failed {
+		return nil, io.EOF
+	}
+
+	var line []byte
+	tooLong := false
+	for {
+		chunk, isPrefix, err := d.reader.ReadLine()
+		if err != nil {
+			if err != io.EOF {
+				d.failed = true
+			}
+			return nil, err
+		}
+		if len(line)+len(chunk) > maxLineLength {
+			tooLong = true
+			line = nil
+		}
+		if !tooLong {
+			line = append(line, chunk...)
+		}
+		if !isPrefix {
+			break
+		}
+	}
+
+	d.line++
+	if tooLong {
+		return nil, &DecodeError{Line: d.line, Err: bufio.ErrTooLong}
+	}
+	return line, nil
+}
+
+// decodeEvent decodes a single line of json into an Event
+func decodeEvent(raw []byte) (*Event, error) {
+	var msg Message
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		return nil, err
+	}
+
+	event := &Event{Type: msg.Type, Raw: raw}
+
+	var err error
+	switch msg.Type {
+	case "status":
+		event.Status = &StatusMessage{}
+		err = json.Unmarshal(raw, event.Status)
+	case "summary":
+		event.Summary = &SummaryMessage{}
+		err = json.Unmarshal(raw, event.Summary)
+	case "dfb":
+		event.DFB = &DFBMessage{}
+		err = json.Unmarshal(raw, event.DFB)
+	case "error":
+		event.Error = &ErrorMessage{}
+		err = json.Unmarshal(raw, event.Error)
+	case "verbose_status":
+		event.VerboseStatus = &VerboseStatusMessage{}
+		err = json.Unmarshal(raw, event.VerboseStatus)
+	case "":
+		err = fmt.Errorf("message has no message_type")
+	default:
+		err = fmt.Errorf("unknown message_type %q", msg.Type)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return event, nil
+}
@@ -0,0 +1,43 @@
+package restic
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDecoderSkipsLinesThatAreTooLong(t *testing.T) {
+	input := strings.Join([]string{
+		`{"message_type":"status","percent_done":0.5}`,
+		`{"message_type":"verbose_status","action":"new","item":"/` + strings.Repeat("a", maxLineLength) + `"}`,
+		``,
+		`not json`,
+		`{"message_type":"summary","snapshot_id":"3f2c8a91"}`,
+	}, "\n")
+	decoder := NewDecoder(strings.NewReader(input))
+
+	event, err := decoder.Decode()
+	if err != nil || event.Type != "status" || event.Line != 1 {
+		t.Fatalf("got %+v, %v, want status on line 1", event, err)
+	}
+
+	_, err = decoder.Decode()
+	if decodeErr, ok := err.(*DecodeError); !ok || decodeErr.Err != bufio.ErrTooLong || decodeErr.Line != 2 {
+		t.Fatalf("got %v, want line too long on line 2", err)
+	}
+
+	_, err = decoder.Decode()
+	if decodeErr, ok := err.(*DecodeError); !ok || decodeErr.Line != 4 {
+		t.Fatalf("got %v, want decode error on line 4", err)
+	}
+
+	event, err = decoder.Decode()
+	if err != nil || event.Type != "summary" || event.Summary.SnapshotID != "3f2c8a91" || event.Line != 5 {
+		t.Fatalf("got %+v, %v, want summary on line 5", event, err)
+	}
+
+	if _, err := decoder.Decode(); err != io.EOF {
+		t.Fatalf("got %v, want io.EOF", err)
+	}
+}
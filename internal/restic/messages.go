@@ -9,7 +9,6 @@ import (
 // Message should match any restic message
 type Message struct {
 	Type string `json:"message_type"`
-	Body string
 }
 
 // StatusMessage should match status messages from restic
@@ -70,11 +69,12 @@ func (msg *StatusMessage) GetStatusString() string {
 	)
 }
 
-// StatusMessageFromString will create a StatusMessage from given string
-func StatusMessageFromString(data string) StatusMessage {
+// StatusMessageFromString will create a StatusMessage from given string, returns an
+// error if data is not valid json for the message
+func StatusMessageFromString(data string) (StatusMessage, error) {
 	var status StatusMessage
-	json.Unmarshal([]byte(data), &status)
-	return status
+	err := json.Unmarshal([]byte(data), &status)
+	return status, err
 }
 
 // SummaryMessage should match summary messages from restic which
@@ -108,11 +108,66 @@ func (msg *SummaryMessage) GetDataProcessedString() string {
 	return bytesToString(msg.BytesProcessed)
 }
 
-// SummaryMessageFromString will create a SummaryMessage from given string
-func SummaryMessageFromString(data string) SummaryMessage {
+// SummaryMessageFromString will create a SummaryMessage from given string, returns an
+// error if data is not valid json for the message
+func SummaryMessageFromString(data string) (SummaryMessage, error) {
 	var summary SummaryMessage
-	json.Unmarshal([]byte(data), &summary)
-	return summary
+	err := json.Unmarshal([]byte(data), &summary)
+	return summary, err
+}
+
+// ErrorMessage should match error messages from restic which are emitted
+// during backup when a file or directory cannot be read
+type ErrorMessage struct {
+	Error  json.RawMessage `json:"error"`
+	During string          `json:"during"`
+	Item   string          `json:"item"`
+}
+
+// GetErrorString returns the error of the message as a string, restic
+// serializes errors either as a string, an object with a message or an
+// object with the fields of the underlying error
+func (msg *ErrorMessage) GetErrorString() string {
+	var str string
+	if err := json.Unmarshal(msg.Error, &str); err == nil {
+		return str
+	}
+
+	var obj struct {
+		Message string `json:"message"`
+		Err     string `json:"Err"`
+	}
+	if err := json.Unmarshal(msg.Error, &obj); err == nil {
+		if obj.Message != "" {
+			return obj.Message
+		}
+		if obj.Err != "" {
+			return obj.Err
+		}
+	}
+	return string(msg.Error)
+}
+
+// VerboseStatusMessage should match verbose status messages from restic which
+// are emitted for every file processed during backup when run with --verbose
+type VerboseStatusMessage struct {
+	Action       string  `json:"action"`
+	Item         string  `json:"item"`
+	Duration     float64 `json:"duration"`
+	DataSize     int     `json:"data_size"`
+	MetadataSize int     `json:"metadata_size"`
+	TotalFiles   int     `json:"total_files"`
+}
+
+// IsChange returns whether the item of the message was new or modified
+func (msg *VerboseStatusMessage) IsChange() bool {
+	return msg.Action == "new" || msg.Action == "modified"
+}
+
+// GetDataSizeString returns a nicely formatted string of the data size of
+// the item eg. 289.0 MiB 3.1 GiB
+func (msg *VerboseStatusMessage) GetDataSizeString() string {
+	return bytesToString(msg.DataSize)
 }
 
 // DFBMessage is not a message from restic, but a custom dfb message,
@@ -124,11 +179,12 @@ type DFBMessage struct {
 	Action string `json:"action"`
 }
 
-// DFBMessageFromString will create a DFBMessage from given string
-func DFBMessageFromString(data string) DFBMessage {
+// DFBMessageFromString will create a DFBMessage from given string, returns an
+// error if data is not valid json for the message
+func DFBMessageFromString(data string) (DFBMessage, error) {
 	var dfb DFBMessage
-	json.Unmarshal([]byte(data), &dfb)
-	return dfb
+	err := json.Unmarshal([]byte(data), &dfb)
+	return dfb, err
 }
 
 // timeToString formats a number of seconds s to a shorter representation
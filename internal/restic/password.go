@@ -0,0 +1,64 @@
+package restic
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"strings"
+)
+
+// PasswordSource is a type that provides the password for a restic repository
+type PasswordSource interface {
+	Password() (string, error)
+}
+
+// StaticPassword is a PasswordSource with a fixed password
+type StaticPassword string
+
+// Password returns the password of StaticPassword p
+func (p StaticPassword) Password() (string, error) {
+	if p == "" {
+		return "", errors.New("password cannot be empty")
+	}
+	return string(p), nil
+}
+
+// EnvPassword is a PasswordSource that reads the password from the
+// environment variable with given name
+type EnvPassword string
+
+// Password returns the value of the environment variable of EnvPassword p
+func (p EnvPassword) Password() (string, error) {
+	password := os.Getenv(string(p))
+	if password == "" {
+		return "", errors.New("environment variable " + string(p) + " is not set")
+	}
+	return password, nil
+}
+
+// ReaderPassword is a PasswordSource that reads the password from the first
+// line of Reader, such as stdin. The line is only read once
+type ReaderPassword struct {
+	Reader io.Reader
+
+	password string
+	read     bool
+}
+
+// Password returns the password read from the reader of ReaderPassword p
+func (p *ReaderPassword) Password() (string, error) {
+	if !p.read {
+		line, err := bufio.NewReader(p.Reader).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		p.password = strings.TrimRight(line, "\r\n")
+		p.read = true
+	}
+
+	if p.password == "" {
+		return "", errors.New("password cannot be empty")
+	}
+	return p.password, nil
+}
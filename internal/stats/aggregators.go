@@ -1,12 +1,117 @@
 package stats
 
-import "errors"
+import (
+	"errors"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+)
 
-// Aggregators is a map of availible aggregators
+// Aggregators is a map of availible aggregators. Percentiles other than the ones
+// listed can be used as pN, eg. p90, and moving averages over any number of
+// buckets as moving-average-N
 var Aggregators = map[string]Aggregator{
-	"sum":        &Sum{},
-	"accumulate": &Accumulate{},
-	"average":    &Average{},
+	"sum":              &Sum{},
+	"accumulate":       &Accumulate{},
+	"average":          &Average{},
+	"min":              &Min{},
+	"max":              &Max{},
+	"median":           &Percentile{P: 50},
+	"p95":              &Percentile{P: 95},
+	"p99":              &Percentile{P: 99},
+	"last":             &Last{},
+	"delta":            &Delta{},
+	"moving-average-3": &MovingAverage{K: 3},
+	"moving-average-7": &MovingAverage{K: 7},
+}
+
+var (
+	// percentilePattern matches the name of a percentile aggregator
+	percentilePattern = regexp.MustCompile(`^p([0-9]{1,2}(\.[0-9]+)?|100)$`)
+
+	// movingAveragePattern matches the name of a moving average aggregator
+	movingAveragePattern = regexp.MustCompile(`^moving-average-([1-9][0-9]*)$`)
+)
+
+// flowAggregators are the aggregators that make sense for metrics of things that
+// happen during a backup, such as data added, where the values can be summed
+var flowAggregators = []string{
+	"sum",
+	"accumulate",
+	"average",
+	"min",
+	"max",
+	"median",
+	"pN",
+	"moving-average-N",
+}
+
+// durationAggregators are the aggregators that make sense for metrics of time
+// taken, summing or accumulating durations does not
+var durationAggregators = []string{
+	"average",
+	"min",
+	"max",
+	"median",
+	"pN",
+	"last",
+	"moving-average-N",
+}
+
+// gaugeAggregators are the aggregators that make sense for metrics of a size
+// at a point in time, such as disk space, where only the latest value is current
+var gaugeAggregators = []string{
+	"last",
+	"average",
+	"min",
+	"max",
+	"median",
+	"delta",
+	"moving-average-N",
+}
+
+// stackedAggregators are the gauge aggregators that make sense for the series
+// of a stacked metric, the changes of the series cannot be stacked
+var stackedAggregators = []string{
+	"last",
+	"average",
+	"min",
+	"max",
+	"median",
+	"moving-average-N",
+}
+
+// forecastAggregators are the gauge aggregators that make sense for a metric that
+// is projected forward, a trend cannot be fitted over changes
+var forecastAggregators = []string{
+	"last",
+	"average",
+	"min",
+	"max",
+	"median",
+}
+
+// ratioAggregators are the aggregators that make sense for metrics of a ratio
+// or share, where summing the values does not
+var ratioAggregators = []string{
+	"average",
+	"min",
+	"max",
+	"median",
+	"pN",
+	"last",
+	"moving-average-N",
+}
+
+// costAggregators are the aggregators that make sense for metrics of money
+// spent, the spend of each date summed, accumulated or smoothed
+var costAggregators = []string{
+	"sum",
+	"accumulate",
+	"average",
+	"moving-average-N",
 }
 
 // Aggregator is a type that provides a method to aggregate values gathered
@@ -15,13 +120,58 @@ type Aggregator interface {
 	Aggregate(output []float64, values []float64) []float64
 }
 
-// NewAggregator returns an aggregator that matches string name
+// NewAggregator returns a new aggregator that matches string name
 func NewAggregator(name string) (Aggregator, error) {
+	if match := percentilePattern.FindStringSubmatch(name); match != nil {
+		p, _ := strconv.ParseFloat(match[1], 64)
+		return &Percentile{P: p}, nil
+	}
+	if match := movingAveragePattern.FindStringSubmatch(name); match != nil {
+		k, _ := strconv.Atoi(match[1])
+		return &MovingAverage{K: k}, nil
+	}
 	if _, ok := Aggregators[name]; !ok {
 		return nil, errors.New("unknown aggregator " + name)
 	}
-	a := Aggregators[name]
-	return a, nil
+	v := reflect.New(reflect.TypeOf(Aggregators[name]).Elem())
+	v.Elem().Set(reflect.ValueOf(Aggregators[name]).Elem())
+	return v.Interface().(Aggregator), nil
+}
+
+// SupportsAggregator returns whether aggregator with given name makes sense
+// for metric m
+func SupportsAggregator(m Metric, name string) bool {
+	if percentilePattern.MatchString(name) {
+		name = "pN"
+	}
+	if movingAveragePattern.MatchString(name) {
+		name = "moving-average-N"
+	}
+	for _, supported := range m.GetAggregators() {
+		if supported == name || (name == "median" && supported == "pN") {
+			return true
+		}
+	}
+	return false
+}
+
+// GetAggregatorName returns the name of aggregator a, as it is given to NewAggregator
+func GetAggregatorName(a Aggregator) string {
+	switch typed := a.(type) {
+	case *Percentile:
+		if typed.P == 50 {
+			return "median"
+		}
+		return "p" + strconv.FormatFloat(typed.P, 'f', -1, 64)
+	case *MovingAverage:
+		return "moving-average-" + strconv.Itoa(typed.K)
+	}
+	for name, aggregator := range Aggregators {
+		if reflect.TypeOf(aggregator) == reflect.TypeOf(a) {
+			return name
+		}
+	}
+	return ""
 }
 
 // Sum is an aggregator that sums the values provided and appends it to output
@@ -69,3 +219,126 @@ func (a *Average) Aggregate(output []float64, values []float64) []float64 {
 	}
 	return append(output, avg)
 }
+
+// Min is an aggregator that appends the smallest of the values provided to output
+type Min struct{}
+
+// Aggregate aggregates values provided into output
+func (a *Min) Aggregate(output []float64, values []float64) []float64 {
+	if len(values) == 0 {
+		return append(output, 0)
+	}
+	min := values[0]
+	for i := range values {
+		min = math.Min(min, values[i])
+	}
+	return append(output, min)
+}
+
+// Max is an aggregator that appends the largest of the values provided to output
+type Max struct{}
+
+// Aggregate aggregates values provided into output
+func (a *Max) Aggregate(output []float64, values []float64) []float64 {
+	if len(values) == 0 {
+		return append(output, 0)
+	}
+	max := values[0]
+	for i := range values {
+		max = math.Max(max, values[i])
+	}
+	return append(output, max)
+}
+
+// Percentile is an aggregator that appends the Pth percentile of the values
+// provided to output, interpolating between the closest values
+type Percentile struct {
+	P float64
+}
+
+// Aggregate aggregates values provided into output
+func (a *Percentile) Aggregate(output []float64, values []float64) []float64 {
+	if len(values) == 0 {
+		return append(output, 0)
+	}
+
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+
+	rank := a.P / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	value := sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+	return append(output, value)
+}
+
+// Last is an aggregator that appends the last of the values provided to output,
+// values are in the order they were recorded
+type Last struct{}
+
+// Aggregate aggregates values provided into output
+func (a *Last) Aggregate(output []float64, values []float64) []float64 {
+	if len(values) == 0 {
+		return append(output, 0)
+	}
+	return append(output, values[len(values)-1])
+}
+
+// Delta is an aggregator that appends how much the last value changed since the
+// last value of the previous bucket with values. The first bucket gets the change
+// within the bucket and buckets without values get 0
+type Delta struct {
+	previous    float64
+	hasPrevious bool
+}
+
+// Aggregate aggregates values provided into output
+func (a *Delta) Aggregate(output []float64, values []float64) []float64 {
+	if len(output) == 0 {
+		a.hasPrevious = false
+	}
+	if len(values) == 0 {
+		return append(output, 0)
+	}
+
+	last := values[len(values)-1]
+	from := values[0]
+	if a.hasPrevious {
+		from = a.previous
+	}
+	a.previous = last
+	a.hasPrevious = true
+	return append(output, last-from)
+}
+
+// MovingAverage is an aggregator that appends the mean of the averages of the
+// last K buckets with values, including the current one
+type MovingAverage struct {
+	K int
+
+	window []float64
+}
+
+// Aggregate aggregates values provided into output
+func (a *MovingAverage) Aggregate(output []float64, values []float64) []float64 {
+	if len(output) == 0 {
+		a.window = []float64{}
+	}
+
+	if len(values) > 0 {
+		bucket := (&Average{}).Aggregate([]float64{}, values)[0]
+		a.window = append(a.window, bucket)
+		if len(a.window) > a.K {
+			a.window = a.window[len(a.window)-a.K:]
+		}
+	}
+
+	if len(a.window) == 0 {
+		return append(output, 0)
+	}
+	var sum float64
+	for _, value := range a.window {
+		sum += value
+	}
+	return append(output, sum/float64(len(a.window)))
+}
@@ -0,0 +1,228 @@
+package stats
+
+import (
+	"math"
+	"reflect"
+	"sort"
+	"time"
+)
+
+const (
+	// DefaultAnomalyWindow is the number of previous snapshots of a domain the
+	// baseline of a snapshot is computed from
+	DefaultAnomalyWindow = 20
+
+	// DefaultAnomalyDeviation is how many deviations from the baseline a value must
+	// be to be an anomaly, 3.5 is the usual cut-off for the median absolute deviation
+	DefaultAnomalyDeviation = 3.5
+
+	// anomalyMinRuns is the number of previous snapshots a domain must have in the
+	// baseline before its snapshots are checked for anomalies
+	anomalyMinRuns = 5
+
+	// madScale scales the median absolute deviation to the standard deviation of
+	// normally distributed values
+	madScale = 1.4826
+)
+
+// anomalyField is a field of snapshots that anomalies are detected in
+type anomalyField struct {
+	Field     string
+	Name      string
+	Formatter Formatter
+
+	// minSpread is the smallest spread of the baseline, so that a domain that
+	// usually adds nothing is not an anomaly when it adds a few bytes
+	minSpread float64
+
+	// liveOnly is whether the field is only known for snapshots recorded during
+	// backup, backfilled snapshots record it as 0
+	liveOnly bool
+}
+
+// anomalyFields are the fields of snapshots anomalies are detected in
+var anomalyFields = []anomalyField{
+	{Field: "DataAdded", Name: "data added", Formatter: &BytesFormatter{}, minSpread: 10 * 1024 * 1024, liveOnly: true},
+	{Field: "TotalDuration", Name: "duration", Formatter: &TimeFormatter{}, minSpread: 5, liveOnly: true},
+	{Field: "TotalFilesProcessed", Name: "files processed", Formatter: &AmountFormatter{}, minSpread: 10},
+}
+
+// AnomalyOptions are the options anomalies are detected with
+type AnomalyOptions struct {
+	// Window is the number of previous snapshots of a domain in the baseline
+	Window int
+
+	// Deviation is how many deviations from the baseline a value must be
+	Deviation float64
+}
+
+// DefaultAnomalyOptions returns the options anomalies are detected with by default
+func DefaultAnomalyOptions() AnomalyOptions {
+	return AnomalyOptions{Window: DefaultAnomalyWindow, Deviation: DefaultAnomalyDeviation}
+}
+
+// Anomaly is a snapshot with a value far from the baseline of the snapshots
+// before it of the same domain. Deviation is the distance from the baseline in
+// deviations, negative if the value is below the baseline
+type Anomaly struct {
+	Group      string
+	Repo       string
+	Domain     string
+	SnapshotID string
+	Date       time.Time
+	Field      string
+	Name       string
+	Formatter  Formatter
+	Value      float64
+	Baseline   float64
+	Deviation  float64
+}
+
+// AnomalyMetric is a Metric of a field of snapshots that anomalies are detected in
+type AnomalyMetric interface {
+	Metric
+	GetAnomalyField() string
+}
+
+// DetectAnomalies returns the anomalies of the snapshots of given group, repo
+// and domain in db, in the order they were taken. The baseline of a snapshot is
+// the median of the previous snapshots of the same domain in the same repo and
+// group, and the deviation is the scaled median absolute deviation of them
+func DetectAnomalies(db *DB, group string, repo string, domain string, options AnomalyOptions) []Anomaly {
+	var anomalies []Anomaly
+	for _, snapshots := range db.snapshotsPerDomain(group, repo, domain) {
+		for i, snapshot := range snapshots {
+			previous := snapshots[maxInt(0, i-options.Window):i]
+			for _, field := range anomalyFields {
+				if anomaly, ok := detectAnomaly(snapshot, previous, field, options.Deviation); ok {
+					anomalies = append(anomalies, anomaly)
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(anomalies, func(i, j int) bool {
+		return anomalies[i].Date.Before(anomalies[j].Date)
+	})
+	return anomalies
+}
+
+// GetAnomaliesOf returns the anomalies in the field of metric m, for the group,
+// repo and domain of m
+func GetAnomaliesOf(db *DB, m AnomalyMetric, options AnomalyOptions) []Anomaly {
+	domain := AllDomains
+	if m.SupportsDomains() {
+		domain = m.GetMetadata("domain")
+	}
+
+	var anomalies []Anomaly
+	for _, anomaly := range DetectAnomalies(db, m.GetMetadata("group"), m.GetMetadata("repo"), domain, options) {
+		if anomaly.Field == m.GetAnomalyField() {
+			anomalies = append(anomalies, anomaly)
+		}
+	}
+	return anomalies
+}
+
+// detectAnomaly returns the anomaly of given field of snapshot compared with the
+// baseline of previous, if it is more than deviation deviations from it
+func detectAnomaly(snapshot *SnapshotSummary, previous []*SnapshotSummary, field anomalyField, deviation float64) (Anomaly, bool) {
+	if field.liveOnly && snapshot.Source != SourceLive {
+		return Anomaly{}, false
+	}
+
+	var values []float64
+	for _, p := range previous {
+		if !field.liveOnly || p.Source == SourceLive {
+			values = append(values, fieldValue(p, field.Field))
+		}
+	}
+	if len(values) < anomalyMinRuns {
+		return Anomaly{}, false
+	}
+	median := &Percentile{P: 50}
+	baseline := median.Aggregate([]float64{}, values)[0]
+
+	distances := make([]float64, len(values))
+	for i, value := range values {
+		distances[i] = math.Abs(value - baseline)
+	}
+	spread := math.Max(median.Aggregate([]float64{}, distances)[0]*madScale, field.minSpread)
+
+	value := fieldValue(snapshot, field.Field)
+	d := (value - baseline) / spread
+	if math.Abs(d) <= deviation {
+		return Anomaly{}, false
+	}
+	return Anomaly{
+		Group:      snapshot.Group,
+		Repo:       snapshot.Repo,
+		Domain:     snapshot.Domain,
+		SnapshotID: snapshot.SnapshotID,
+		Date:       snapshot.Date,
+		Field:      field.Field,
+		Name:       field.Name,
+		Formatter:  field.Formatter,
+		Value:      value,
+		Baseline:   baseline,
+		Deviation:  d,
+	}, true
+}
+
+// snapshotsPerDomain returns the snapshots of given group, repo and domain in
+// db, per domain of each group and repo, in the order they were taken
+func (db *DB) snapshotsPerDomain(group string, repo string, domain string) map[sourceKey][]*SnapshotSummary {
+	txn := db.memdb.Txn(false)
+	defer txn.Abort()
+
+	records, err := txn.Get("snapshot", "id")
+	if err != nil {
+		panic("failed to fetch snapshots from db. " + err.Error())
+	}
+
+	snapshots := make(map[sourceKey][]*SnapshotSummary)
+	for obj := records.Next(); obj != nil; obj = records.Next() {
+		v := reflect.Indirect(reflect.ValueOf(obj))
+		if !fieldMatches(v, "Group", group) || !fieldMatches(v, "Repo", repo) || !fieldMatches(v, "Domain", domain) {
+			continue
+		}
+		key := keyOf(obj)
+		snapshots[key] = append(snapshots[key], obj.(*SnapshotSummary))
+	}
+
+	for _, s := range snapshots {
+		sort.SliceStable(s, func(i, j int) bool {
+			return s[i].Date.Before(s[j].Date)
+		})
+	}
+	return snapshots
+}
+
+// anomalyMarks returns the label and value of each date of given labels and
+// values that an anomaly was detected in, the last label is assumed to be as
+// long as the one before it
+func anomalyMarks(labels []time.Time, values []float64, anomalies []Anomaly) ([]time.Time, []float64) {
+	var markedLabels []time.Time
+	var markedValues []float64
+	for i, label := range labels {
+		var end time.Time
+		switch {
+		case i+1 < len(labels):
+			end = labels[i+1]
+		case i > 0:
+			end = label.Add(label.Sub(labels[i-1]))
+		default:
+			end = label.AddDate(0, 0, 1)
+		}
+
+		for _, anomaly := range anomalies {
+			if anomaly.Date.Before(label) || !anomaly.Date.Before(end) || math.IsNaN(values[i]) {
+				continue
+			}
+			markedLabels = append(markedLabels, label)
+			markedValues = append(markedValues, values[i])
+			break
+		}
+	}
+	return markedLabels, markedValues
+}
@@ -0,0 +1,144 @@
+package stats
+
+import (
+	"strconv"
+
+	"github.com/nattvara/dfb/internal/restic"
+)
+
+// BackfillProgress is called by Backfill before a snapshot is processed
+type BackfillProgress func(current int, total int, domain string, snapshot restic.Snapshot)
+
+// Backfill regenerates the snapshots, domain raw data and domain restore size
+// rows of given group from the snapshots in the repo of client. A snapshot is
+// mapped to a domain by its tags, snapshots not tagged with any of given domains
+// are ignored. Rows that already exist for a snapshot are not written again, and
+// written rows are marked with SourceBackfill. Returns the number of snapshots
+// rows were written for
+//
+// The summary restic emits after a backup is not available for an existing
+// snapshot, so only the total number of files and bytes processed is known for
+// backfilled snapshot rows, their other values are 0
+func Backfill(client *restic.Client, groupName string, repoName string, domains []string, progress BackfillProgress) (int, error) {
+	statsDir := StatsDir(groupName)
+	if _, err := MigrateDir(statsDir); err != nil {
+		return 0, err
+	}
+
+	snapshots, err := client.Snapshots()
+	if err != nil {
+		return 0, err
+	}
+
+	summaries := make(map[string]string)
+	for _, record := range csvReadSummaries(statsDir + "/" + snapshotsSchema.Filename) {
+		summaries[record.SnapshotID] = record.Source
+	}
+	rawData := make(map[string]bool)
+	for _, record := range csvReadDomainRawData(statsDir + "/" + domainRawDataSchema.Filename) {
+		rawData[record.SnapshotID] = true
+	}
+	restoreSizes := make(map[string]bool)
+	for _, record := range csvReadDomainRestoreSize(statsDir + "/" + domainRestoreSizeSchema.Filename) {
+		restoreSizes[record.SnapshotID] = true
+	}
+
+	var backfilled int
+	for i, snapshot := range snapshots {
+		domain := snapshotDomain(snapshot, domains)
+		if domain == "" {
+			continue
+		}
+		// live summaries may be recorded with the short id of the snapshot
+		source, hasSummary := "", false
+		hasRawData, hasRestoreSize := false, false
+		for _, id := range snapshotIDs(snapshot.ID) {
+			if s, ok := summaries[id]; ok {
+				source, hasSummary = s, true
+			}
+			hasRawData = hasRawData || rawData[id]
+			hasRestoreSize = hasRestoreSize || restoreSizes[id]
+		}
+		// live rows of the domain stats were recorded without a snapshot id,
+		// but are always recorded together with the snapshot row
+		if source == SourceLive || (hasSummary && hasRawData && hasRestoreSize) {
+			continue
+		}
+
+		if progress != nil {
+			progress(i+1, len(snapshots), domain, snapshot)
+		}
+
+		raw, err := client.Stats(restic.StatsModeRawData, snapshot.ID)
+		if err != nil {
+			return backfilled, err
+		}
+		restore, err := client.Stats(restic.StatsModeRestoreSize, snapshot.ID)
+		if err != nil {
+			return backfilled, err
+		}
+
+		values := map[string]string{
+			"snapshot_id": snapshot.ID,
+			"group":       groupName,
+			"domain":      domain,
+			"repo":        repoName,
+			"date":        snapshot.Time.Format(csvDateLayout),
+			"source":      SourceBackfill,
+		}
+
+		if !hasRawData {
+			values["total_size"] = strconv.FormatInt(raw.TotalSize, 10)
+			values["total_file_count"] = strconv.Itoa(raw.TotalFileCount)
+			values["total_blob_count"] = strconv.Itoa(raw.TotalBlobCount)
+			if err := appendValues(statsDir, domainRawDataSchema, values); err != nil {
+				return backfilled, err
+			}
+		}
+
+		if !hasRestoreSize {
+			values["total_size"] = strconv.FormatInt(restore.TotalSize, 10)
+			values["total_file_count"] = strconv.Itoa(restore.TotalFileCount)
+			if err := appendValues(statsDir, domainRestoreSizeSchema, values); err != nil {
+				return backfilled, err
+			}
+		}
+
+		if !hasSummary {
+			values["total_files_processed"] = strconv.Itoa(restore.TotalFileCount)
+			values["total_bytes_processed"] = strconv.FormatInt(restore.TotalSize, 10)
+			if err := appendValues(statsDir, snapshotsSchema, values); err != nil {
+				return backfilled, err
+			}
+		}
+
+		backfilled++
+	}
+
+	return backfilled, nil
+}
+
+// snapshotDomain returns the first of given domains snapshot is tagged with,
+// or an empty string if it is not tagged with any of them
+func snapshotDomain(snapshot restic.Snapshot, domains []string) string {
+	for _, domain := range domains {
+		if snapshot.HasTag(domain) {
+			return domain
+		}
+	}
+	return ""
+}
+
+// appendValues appends a row with given values to the file of given schema in
+// statsDir, numeric columns without a value are written as 0
+func appendValues(statsDir string, schema *csvSchema, values map[string]string) error {
+	var record []string
+	for _, column := range schema.Columns {
+		value, ok := values[column]
+		if !ok && columnKinds[column] != columnString {
+			value = "0"
+		}
+		record = append(record, value)
+	}
+	return appendRecords(statsDir+"/"+schema.Filename, [][]string{record})
+}
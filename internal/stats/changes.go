@@ -0,0 +1,125 @@
+package stats
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/nattvara/dfb/internal/restic"
+)
+
+var changesSchema = &csvSchema{
+	Columns:       []string{"action", "item", "data_size"},
+	LegacyColumns: []string{"action", "item", "data_size"},
+}
+
+// Change is a file or directory that was new or modified in a snapshot
+type Change struct {
+	Action   string
+	Item     string
+	DataSize int
+}
+
+// ChangesDir returns the path to the directory where the changed files of the
+// snapshots of given group are stored
+func ChangesDir(groupName string) string {
+	return StatsDir(groupName) + "/changes"
+}
+
+// RecordChanges reads restic events from r and stores the items that were new
+// or modified in a csv file named after the snapshot in the changes directory
+// of given group. Lines that cannot be decoded are ignored. The file is only
+// written if a summary message is read, returns the path of the file
+func RecordChanges(r io.Reader, groupName string) (string, error) {
+	dir := ChangesDir(groupName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	pending, err := ioutil.TempFile(dir, ".pending-*.csv")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(pending.Name())
+	defer pending.Close()
+
+	if err := writeSchemaHeader(pending, changesSchema); err != nil {
+		return "", err
+	}
+	writer := csv.NewWriter(pending)
+
+	var snapshotID string
+	decoder := restic.NewDecoder(r)
+	for {
+		event, err := decoder.Decode()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			continue
+		}
+
+		switch event.Type {
+		case "verbose_status":
+			if !event.VerboseStatus.IsChange() {
+				continue
+			}
+			writer.Write([]string{
+				event.VerboseStatus.Action,
+				event.VerboseStatus.Item,
+				strconv.Itoa(event.VerboseStatus.DataSize),
+			})
+		case "summary":
+			snapshotID = event.Summary.SnapshotID
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return "", err
+	}
+
+	if snapshotID == "" {
+		return "", errors.New("no summary message found, the backup did not complete")
+	}
+
+	path := fmt.Sprintf("%s/%s.csv", dir, snapshotID)
+	return path, os.Rename(pending.Name(), path)
+}
+
+// ReadChanges returns the changes recorded for the snapshot of given group
+// matching snapshotID, which may be a unique prefix of the full id
+func ReadChanges(groupName string, snapshotID string) ([]Change, error) {
+	var changes []Change
+
+	matches, err := filepath.Glob(fmt.Sprintf("%s/%s*.csv", ChangesDir(groupName), snapshotID))
+	if err != nil {
+		return changes, err
+	}
+	if len(matches) == 0 {
+		return changes, fmt.Errorf("no changes recorded for snapshot %s", snapshotID)
+	}
+	if len(matches) > 1 {
+		return changes, fmt.Errorf("snapshot id %s is ambiguous", snapshotID)
+	}
+
+	it := &csvFileIterator{}
+	it.Open(matches[0], changesSchema)
+	defer it.Close()
+
+	for record := it.Next(); record != nil; record = it.Next() {
+		dataSize, _ := strconv.Atoi(it.Get(record, "data_size"))
+		changes = append(changes, Change{
+			Action:   it.Get(record, "action"),
+			Item:     it.Get(record, "item"),
+			DataSize: dataSize,
+		})
+	}
+
+	return changes, nil
+}
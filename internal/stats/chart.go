@@ -3,7 +3,12 @@ package stats
 import (
 	"bytes"
 	"errors"
+	"fmt"
+	"io"
+	"math"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/nattvara/dfb/internal/fonts"
 
@@ -12,18 +17,281 @@ import (
 	"github.com/wcharczuk/go-chart/util"
 )
 
-// LineChart is a line chart for a Metric
+const (
+	// ChartFormatPNG is an identifier for writing a chart as a png image
+	ChartFormatPNG = "png"
+
+	// ChartFormatSVG is an identifier for writing a chart as an svg image
+	ChartFormatSVG = "svg"
+)
+
+// Chart is a chart of one or more metrics that can be written as an image
+type Chart interface {
+	WriteToFile(path string) error
+	Write(w io.Writer, format string) error
+}
+
+// LineChart is a line chart for a Metric, the dates of Anomalies are marked
+// with a dot on the line
 type LineChart struct {
 	Metric     Metric
 	Aggregator Aggregator
+	Anomalies  []Anomaly
 }
 
 // WriteToFile writes LineChart c to file at given path
 func (c *LineChart) WriteToFile(path string) error {
-	graph := c.createGraph()
+	return writeGraphToFile(c.createGraph(), path)
+}
+
+// Write writes LineChart c to w as an image in given format
+func (c *LineChart) Write(w io.Writer, format string) error {
+	return writeGraph(c.createGraph(), w, format)
+}
+
+// createGraph creates a graph for LineChart c
+func (c *LineChart) createGraph() chart.Chart {
+	graph := newGraph(c.Metric.GetTitle(), c.Metric.GetDateLayout(), c.Metric.GetFormatter())
+	labels := c.Metric.GetLabels()
+	values := c.Metric.GetValues(c.Aggregator)
+	graph.Series = []chart.Series{
+		newTimeSeries("", labels, values, drawing.ColorFromHex(seriesColors[0])),
+	}
+	splitAtGaps(&graph)
+	fixEmptyRange(&graph)
+
+	markedLabels, markedValues := anomalyMarks(labels, values, c.Anomalies)
+	if len(markedLabels) > 0 {
+		color := drawing.ColorFromHex(seriesColors[3])
+		marks := newTimeSeries("anomalies", markedLabels, markedValues, color)
+		marks.Style.StrokeWidth = chart.Disabled
+		marks.Style.FillColor = drawing.ColorTransparent
+		marks.Style.DotWidth = 10
+		marks.Style.DotColor = color
+		graph.Series = append(graph.Series, marks)
+		graph.Elements = []chart.Renderable{
+			newLegend(&graph),
+		}
+	}
+	return graph
+}
+
+// ForecastChart is a line chart for a ForecastMetric, with the forecast drawn as a
+// dashed extension of the values and the quota, if any, as a dashed line
+type ForecastChart struct {
+	Metric     ForecastMetric
+	Aggregator Aggregator
+}
+
+// WriteToFile writes ForecastChart c to file at given path
+func (c *ForecastChart) WriteToFile(path string) error {
+	return writeGraphToFile(c.createGraph(), path)
+}
+
+// Write writes ForecastChart c to w as an image in given format
+func (c *ForecastChart) Write(w io.Writer, format string) error {
+	return writeGraph(c.createGraph(), w, format)
+}
+
+// createGraph creates a graph for ForecastChart c
+func (c *ForecastChart) createGraph() chart.Chart {
+	formatter := c.Metric.GetFormatter()
+	graph := newGraph(c.Metric.GetTitle(), c.Metric.GetDateLayout(), formatter)
+
+	labels := c.Metric.GetLabels()
+	color := drawing.ColorFromHex(seriesColors[0])
+	graph.Series = []chart.Series{
+		newTimeSeries("", labels, c.Metric.GetValues(c.Aggregator), color),
+	}
+
+	forecast := c.Metric.GetForecast(c.Aggregator)
+	if len(forecast.Values) > 1 {
+		projection := newTimeSeries("forecast", forecast.Labels, forecast.Values, color)
+		projection.Style.StrokeDashArray = []float64{16, 12}
+		projection.Style.FillColor = color.WithAlpha(15)
+		graph.Series = append(graph.Series, projection)
+	}
+
+	if forecast.Quota > 0 && len(labels) > 0 {
+		name := "quota " + formatter.Format(forecast.Quota)
+		if !forecast.QuotaDate.IsZero() {
+			name += ", reached " + forecast.QuotaDate.Format("2006-01-02")
+		}
+		end := labels[len(labels)-1]
+		if len(forecast.Labels) > 0 {
+			end = forecast.Labels[len(forecast.Labels)-1]
+		}
+		quota := newTimeSeries(
+			name,
+			[]time.Time{labels[0], end},
+			[]float64{forecast.Quota, forecast.Quota},
+			drawing.ColorFromHex(seriesColors[3]),
+		)
+		quota.Style.StrokeDashArray = []float64{8, 8}
+		quota.Style.FillColor = drawing.ColorTransparent
+		graph.Series = append(graph.Series, quota)
+	}
+
+	if len(graph.Series) > 1 {
+		graph.Elements = []chart.Renderable{
+			newLegend(&graph),
+		}
+	}
+	splitAtGaps(&graph)
+	fixEmptyRange(&graph)
+	return graph
+}
+
+// ComparisonChart is a line chart with a series for each domain or repo of a Comparison
+type ComparisonChart struct {
+	Comparison *Comparison
+	Aggregator Aggregator
+}
+
+// WriteToFile writes ComparisonChart c to file at given path
+func (c *ComparisonChart) WriteToFile(path string) error {
+	return writeGraphToFile(c.createGraph(), path)
+}
+
+// Write writes ComparisonChart c to w as an image in given format
+func (c *ComparisonChart) Write(w io.Writer, format string) error {
+	return writeGraph(c.createGraph(), w, format)
+}
+
+// createGraph creates a graph for ComparisonChart c
+func (c *ComparisonChart) createGraph() chart.Chart {
+	first := c.Comparison.Series[0].Metric
+	graph := newGraph(c.Comparison.Title, first.GetDateLayout(), first.GetFormatter())
+
+	var names []string
+	for _, series := range c.Comparison.Series {
+		names = append(names, series.Name)
+	}
+	colors := SeriesColors(names)
+	for _, series := range c.Comparison.Series {
+		graph.Series = append(graph.Series, newTimeSeries(
+			series.Name,
+			series.Metric.GetLabels(),
+			series.Metric.GetValues(c.Aggregator),
+			colors[series.Name],
+		))
+	}
+	graph.Elements = []chart.Renderable{
+		newLegend(&graph),
+	}
+	splitAtGaps(&graph)
+	fixEmptyRange(&graph)
+	return graph
+}
+
+// StackedChart is a stacked area chart with an area for each series of a StackedMetric
+type StackedChart struct {
+	Metric     StackedMetric
+	Aggregator Aggregator
+}
+
+// WriteToFile writes StackedChart c to file at given path
+func (c *StackedChart) WriteToFile(path string) error {
+	return writeGraphToFile(c.createGraph(), path)
+}
+
+// Write writes StackedChart c to w as an image in given format
+func (c *StackedChart) Write(w io.Writer, format string) error {
+	return writeGraph(c.createGraph(), w, format)
+}
+
+// createGraph creates a graph for StackedChart c, every series is drawn on top
+// of the sum of the series before it, a gap of a series adds nothing. The areas are drawn from the top down with
+// opaque fills so that they do not blend into each other
+func (c *StackedChart) createGraph() chart.Chart {
+	graph := newGraph(c.Metric.GetTitle(), c.Metric.GetDateLayout(), c.Metric.GetFormatter())
+
+	labels := c.Metric.GetLabels()
+	stacked := newGaps(len(labels))
+	var series []chart.Series
+	colors := SeriesColors(c.Metric.GetSeriesNames())
+	for _, name := range c.Metric.GetSeriesNames() {
+		values := c.Metric.GetSeriesValues(name, c.Aggregator)
+		sumWithGaps(stacked, values)
+		top := make([]float64, len(labels))
+		copy(top, stacked)
+
+		color := colors[name]
+		ts := newTimeSeries(name, labels, top, color)
+		ts.Style.FillColor = blendColors(color, drawing.ColorFromHex("424242"), 0.4)
+		series = append([]chart.Series{ts}, series...)
+	}
+	graph.Series = series
+
+	high := 0.0
+	for _, value := range stacked {
+		if !math.IsNaN(value) {
+			high = math.Max(high, value)
+		}
+	}
+	if _, ok := c.Metric.GetFormatter().(*PercentFormatter); ok {
+		high = 100
+	}
+	if high > 0 {
+		graph.YAxis.Range = &chart.ContinuousRange{Min: 0, Max: high}
+	}
+
+	if len(graph.Series) == 0 {
+		graph.Series = []chart.Series{
+			newTimeSeries("", labels, make([]float64, len(labels)), drawing.ColorFromHex(seriesColors[0])),
+		}
+	} else {
+		graph.Elements = []chart.Renderable{
+			newLegend(&graph),
+		}
+	}
+	splitAtGaps(&graph)
+	fixEmptyRange(&graph)
+	return graph
+}
+
+// newLegend returns a legend for the series of graph
+func newLegend(graph *chart.Chart) chart.Renderable {
+	return chart.Legend(graph, chart.Style{
+		FillColor:   drawing.ColorFromHex("424242"),
+		FontColor:   chart.ColorWhite,
+		FontSize:    18,
+		StrokeColor: drawing.ColorFromHex("fff"),
+	})
+}
+
+// blendColors returns an opaque color of a laid over b with given opacity
+func blendColors(a drawing.Color, b drawing.Color, opacity float64) drawing.Color {
+	mix := func(x uint8, y uint8) uint8 {
+		return uint8(float64(x)*opacity + float64(y)*(1-opacity))
+	}
+	return drawing.Color{R: mix(a.R, b.R), G: mix(a.G, b.G), B: mix(a.B, b.B), A: 255}
+}
+
+// writeGraph renders graph to w as an image in given format
+func writeGraph(graph chart.Chart, w io.Writer, format string) error {
+	switch format {
+	case ChartFormatPNG:
+		return graph.Render(chart.PNG, w)
+	case ChartFormatSVG:
+		// the svg renderer sets no viewBox, without it the image is cropped
+		// rather than scaled when drawn at any other size
+		var buffer bytes.Buffer
+		if err := graph.Render(chart.SVG, &buffer); err != nil {
+			return err
+		}
+		size := fmt.Sprintf(`width="%v" height="%v"`, graph.GetWidth(), graph.GetHeight())
+		viewBox := fmt.Sprintf(`viewBox="0 0 %v %v" %s`, graph.GetWidth(), graph.GetHeight(), size)
+		_, err := io.WriteString(w, strings.Replace(buffer.String(), size, viewBox, 1))
+		return err
+	}
+	return errors.New("unknown chart format " + format)
+}
 
+// writeGraphToFile renders graph as png to file at given path
+func writeGraphToFile(graph chart.Chart, path string) error {
 	buffer := bytes.NewBuffer([]byte{})
-	err := graph.Render(chart.PNG, buffer)
+	err := writeGraph(graph, buffer, ChartFormatPNG)
 	if err != nil {
 		panic(err)
 	}
@@ -38,15 +306,89 @@ func (c *LineChart) WriteToFile(path string) error {
 	return nil
 }
 
-// createGraph creates a graph for LineChart c
-func (c *LineChart) createGraph() chart.Chart {
+// newTimeSeries returns a series of given values drawn in given color
+func newTimeSeries(name string, labels []time.Time, values []float64, color drawing.Color) chart.TimeSeries {
+	return chart.TimeSeries{
+		Name:    name,
+		XValues: labels,
+		YValues: values,
+		Style: chart.Style{
+			Show:        true,
+			StrokeColor: color,
+			FillColor:   color.WithAlpha(40),
+			StrokeWidth: 4,
+		},
+	}
+}
+
+// splitAtGaps splits the series of graph into a series per run of values
+// between gaps, as lines are drawn through every value of a series. Only the
+// first part of a series keeps its name so that the legend lists it once, and
+// parts of a single value are drawn as a dot without fill, as the fill of a
+// single value is drawn to the left edge. If all values are gaps, a series of
+// zeros is drawn instead
+func splitAtGaps(graph *chart.Chart) {
+	var series []chart.Series
+	for _, s := range graph.Series {
+		ts := s.(chart.TimeSeries)
+		name := ts.Name
+		start := -1
+		for i := 0; i <= len(ts.YValues); i++ {
+			if i < len(ts.YValues) && !math.IsNaN(ts.YValues[i]) {
+				if start < 0 {
+					start = i
+				}
+				continue
+			}
+			if start < 0 {
+				continue
+			}
+
+			part := ts
+			part.Name = name
+			part.XValues = ts.XValues[start:i]
+			part.YValues = ts.YValues[start:i]
+			if len(part.YValues) == 1 {
+				part.Style.DotWidth = ts.Style.StrokeWidth
+				part.Style.DotColor = ts.Style.StrokeColor
+				part.Style.FillColor = drawing.ColorTransparent
+			}
+			series = append(series, part)
+			name = ""
+			start = -1
+		}
+	}
+
+	if len(series) == 0 && len(graph.Series) > 0 {
+		ts := graph.Series[0].(chart.TimeSeries)
+		ts.YValues = make([]float64, len(ts.XValues))
+		series = append(series, ts)
+	}
+	graph.Series = series
+}
+
+// fixEmptyRange sets a fixed range on the y axis of graph if all values of
+// its series are 0, as the range cannot be computed from the values then
+func fixEmptyRange(graph *chart.Chart) {
+	for _, series := range graph.Series {
+		for _, value := range series.(chart.TimeSeries).YValues {
+			if value != 0 {
+				return
+			}
+		}
+	}
+	graph.YAxis.Range = &chart.ContinuousRange{Min: 0, Max: 1}
+}
+
+// newGraph creates a graph without any series, styled the same way for all charts
+func newGraph(title string, dateLayout string, formatter Formatter) chart.Chart {
 	latoRegular := fonts.GetFont(fonts.LatoRegular)
 	latoBlack := fonts.GetFont(fonts.LatoBlack)
 
 	return chart.Chart{
 		Width:  2048,
 		Height: 1024,
-		Title:  c.Metric.GetTitle(),
+		Title:  title,
 		TitleStyle: chart.Style{
 			Padding: chart.Box{
 				Top: 50,
@@ -85,7 +427,7 @@ func (c *LineChart) createGraph() chart.Chart {
 			ValueFormatter: func(v interface{}) string {
 				typed := v.(float64)
 				typedDate := util.Time.FromFloat64(typed)
-				return typedDate.Format(c.Metric.GetDateLayout())
+				return typedDate.Format(dateLayout)
 			},
 		},
 		YAxis: chart.YAxis{
@@ -102,19 +444,7 @@ func (c *LineChart) createGraph() chart.Chart {
 				StrokeWidth: 3,
 			},
 			ValueFormatter: func(v interface{}) string {
-				return c.Metric.GetFormatter().Format(v.(float64))
-			},
-		},
-		Series: []chart.Series{
-			chart.TimeSeries{
-				XValues: c.Metric.GetLabels(),
-				YValues: c.Metric.GetValues(c.Aggregator),
-				Style: chart.Style{
-					Show:        true,
-					StrokeColor: drawing.ColorFromHex("13c158"),
-					FillColor:   drawing.ColorFromHex("13c158").WithAlpha(40),
-					StrokeWidth: 4,
-				},
+				return formatter.Format(v.(float64))
 			},
 		},
 	}
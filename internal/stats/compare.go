@@ -0,0 +1,156 @@
+package stats
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wcharczuk/go-chart/drawing"
+)
+
+// seriesColors are the colors used for the series of a comparison, the first
+// one is the color of a chart with a single series
+var seriesColors = []string{
+	"13c158",
+	"2d9cdb",
+	"f2994a",
+	"eb5757",
+	"bb6bd9",
+	"f2c94c",
+	"56ccf2",
+	"6fcf97",
+	"ff8ac5",
+	"b0b0b0",
+}
+
+// SeriesColors returns the color of each of given series names. The names get
+// the colors in sorted order, so that every series has a color of its own unless
+// there are more series than colors, and the same series get the same colors
+func SeriesColors(names []string) map[string]drawing.Color {
+	sorted := append([]string{}, names...)
+	sort.Strings(sorted)
+
+	colors := make(map[string]drawing.Color)
+	for i, name := range sorted {
+		colors[name] = drawing.ColorFromHex(seriesColors[i%len(seriesColors)])
+	}
+	return colors
+}
+
+// Comparison is a metric for several domains or repos of a group, with one
+// series per domain or repo
+type Comparison struct {
+	Title  string
+	Series []ComparisonSeries
+}
+
+// ComparisonSeries is a named series of a Comparison
+type ComparisonSeries struct {
+	Name   string
+	Metric Metric
+}
+
+// NewComparison returns a new comparison of metric with given name, with a
+// series for each combination of given repos and domains
+func NewComparison(name string, repos []string, group string, domains []string, timeUnit string, aggregator string) (*Comparison, error) {
+	c := &Comparison{}
+
+	for _, repo := range repos {
+		for _, domain := range domains {
+			m, err := NewMetric(name, repo, group, domain, timeUnit, aggregator)
+			if err != nil {
+				return nil, err
+			}
+			if err := checkPricing(m); err != nil {
+				return nil, err
+			}
+			if len(domains) > 1 && !m.SupportsDomains() {
+				return nil, errors.New("metric " + name + " does not support domains")
+			}
+
+			repoName, domainName := repo, domain
+			if repoName == AllRepos {
+				repoName = "all repos"
+			}
+			if domainName == AllDomains {
+				domainName = "all domains"
+			}
+
+			var seriesName string
+			switch {
+			case len(repos) == 1:
+				seriesName = domainName
+			case len(domains) == 1:
+				seriesName = repoName
+			default:
+				seriesName = repoName + "/" + domainName
+			}
+
+			c.Series = append(c.Series, ComparisonSeries{Name: seriesName, Metric: m})
+		}
+	}
+
+	if len(c.Series) == 0 {
+		return nil, errors.New("nothing to compare")
+	}
+
+	c.setTitle(repos, group, domains, aggregator)
+	return c, nil
+}
+
+// setTitle sets the title of Comparison c, the first series is used for
+// the name of the metric
+func (c *Comparison) setTitle(repos []string, group string, domains []string, aggregator string) {
+	var compared string
+	if len(domains) > 1 {
+		compared = "domains " + strings.Join(domains, ", ")
+	} else if domains[0] == AllDomains {
+		compared = "all domains"
+	} else {
+		compared = domains[0]
+	}
+
+	repo := repoTitle(repos[0])
+	if len(repos) > 1 {
+		var names []string
+		for _, name := range repos {
+			if name == AllRepos {
+				name = "all repos"
+			}
+			names = append(names, name)
+		}
+		repo = "repos " + strings.Join(names, ", ")
+	}
+
+	c.Title = strings.TrimSpace(fmt.Sprintf(
+		"%s %s %s in %s of %s",
+		aggregator,
+		c.Series[0].Metric.GetName(),
+		compared,
+		groupTitle(group),
+		repo,
+	))
+}
+
+// SetGapFill sets the gap fill strategy of every series of Comparison c
+func (c *Comparison) SetGapFill(strategy string) {
+	for _, series := range c.Series {
+		series.Metric.SetGapFill(strategy)
+	}
+}
+
+// SetDateRange sets the date range of every series of Comparison c
+func (c *Comparison) SetDateRange(from time.Time, to time.Time) {
+	for _, series := range c.Series {
+		series.Metric.SetDateRange(from, to)
+	}
+}
+
+// FetchDataFromDB fetches data for every series of Comparison c
+func (c *Comparison) FetchDataFromDB(db *DB, timeUnit string, timeLength int) {
+	for _, series := range c.Series {
+		series.Metric.FetchDataFromDB(db, timeUnit, timeLength)
+	}
+}
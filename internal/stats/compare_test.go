@@ -0,0 +1,21 @@
+package stats
+
+import "testing"
+
+func TestSeriesColorsAreDistinct(t *testing.T) {
+	names := []string{"photos", "docs", "proj", "music", "mail", "code", "notes", "videos", "books", "games"}
+	colors := SeriesColors(names)
+
+	seen := make(map[string]string)
+	for _, name := range names {
+		hex := colors[name].String()
+		if other, ok := seen[hex]; ok {
+			t.Errorf("%s and %s got the same color %s", name, other, hex)
+		}
+		seen[hex] = name
+	}
+
+	if SeriesColors([]string{"docs", "proj"})["docs"] != SeriesColors([]string{"proj", "docs"})["docs"] {
+		t.Error("got different colors for the same series in another order")
+	}
+}
@@ -1,87 +1,239 @@
 package stats
 
 import (
+	"bufio"
 	"encoding/csv"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"os"
 	"strconv"
 	"strings"
 	"time"
 )
 
+const (
+	// SourceLive marks a row recorded by dfb during backup
+	SourceLive = "live"
+
+	// SourceBackfill marks a row regenerated from an existing repository, see Backfill
+	SourceBackfill = "backfill"
+
+	// SchemaVersion is the version of the layout of the csv files in the stats directory
+	SchemaVersion = 3
+
+	// schemaVersionPrefix prefixes the first line of a csv file, followed by the schema version
+	schemaVersionPrefix = "#dfb-stats-schema:"
+
+	// csvDateLayout is the layout used for the date column written by dfb
+	csvDateLayout = "2006-01-02T15:04:05Z0700"
+)
+
+// csvSchema describes the columns of one of the csv files in the stats directory
+type csvSchema struct {
+	Filename string
+
+	// Columns is the header of the file in the current schema version
+	Columns []string
+
+	// LegacyColumns is the column order of header-less files (schema version 1),
+	// which was the order restic happened to emit the fields of its json output
+	LegacyColumns []string
+
+	// Defaults are the values of columns added in later schema versions, used
+	// when migrating rows written before the column existed
+	Defaults map[string]string
+}
+
+var snapshotsSchema = &csvSchema{
+	Filename: "snapshots.csv",
+	Columns: []string{
+		"snapshot_id", "files_new", "files_changed", "files_unmodified",
+		"dirs_new", "dirs_changed", "dirs_unmodified", "data_blobs", "tree_blobs",
+		"data_added", "total_files_processed", "total_bytes_processed", "total_duration",
+		"group", "domain", "repo", "date", "source",
+	},
+	LegacyColumns: []string{
+		"message_type", "files_new", "files_changed", "files_unmodified",
+		"dirs_new", "dirs_changed", "dirs_unmodified", "data_blobs", "tree_blobs",
+		"data_added", "total_files_processed", "total_bytes_processed", "total_duration",
+		"snapshot_id", "group", "domain", "repo", "date",
+	},
+	Defaults: map[string]string{"source": SourceLive},
+}
+
+var repoBackupTimeSchema = &csvSchema{
+	Filename:      "repo_time_took.csv",
+	Columns:       []string{"took", "group", "repo", "date"},
+	LegacyColumns: []string{"took", "group", "repo", "date"},
+}
+
+var repoRawDataSchema = &csvSchema{
+	Filename:      "repo_raw_data.csv",
+	Columns:       []string{"total_size", "total_file_count", "total_blob_count", "group", "repo", "date"},
+	LegacyColumns: []string{"total_size", "total_file_count", "total_blob_count", "group", "repo", "date"},
+}
+
+var domainRawDataSchema = &csvSchema{
+	Filename: "domain_raw_data.csv",
+	Columns: []string{
+		"total_size", "total_file_count", "total_blob_count",
+		"group", "domain", "repo", "date", "snapshot_id", "source",
+	},
+	LegacyColumns: []string{"total_size", "total_file_count", "total_blob_count", "group", "domain", "repo", "date"},
+	Defaults:      map[string]string{"source": SourceLive},
+}
+
+var domainRestoreSizeSchema = &csvSchema{
+	Filename: "domain_restore_size.csv",
+	Columns: []string{
+		"total_size", "total_file_count",
+		"group", "domain", "repo", "date", "snapshot_id", "source",
+	},
+	LegacyColumns: []string{"total_size", "total_file_count", "group", "domain", "repo", "date"},
+	Defaults:      map[string]string{"source": SourceLive},
+}
+
+// csvSchemas contains the schemas of all csv files in the stats directory
+var csvSchemas = []*csvSchema{
+	snapshotsSchema,
+	repoBackupTimeSchema,
+	repoRawDataSchema,
+	domainRawDataSchema,
+	domainRestoreSizeSchema,
+}
+
+const (
+	columnString = iota
+	columnInt
+	columnFloat
+	columnDate
+)
+
+// columnKinds maps the columns of the stats files to the kind of value they
+// store, columns not listed store strings
+var columnKinds = map[string]int{
+	"files_new":             columnInt,
+	"files_changed":         columnInt,
+	"files_unmodified":      columnInt,
+	"dirs_new":              columnInt,
+	"dirs_changed":          columnInt,
+	"dirs_unmodified":       columnInt,
+	"data_blobs":            columnInt,
+	"tree_blobs":            columnInt,
+	"data_added":            columnInt,
+	"total_files_processed": columnInt,
+	"total_bytes_processed": columnInt,
+	"total_duration":        columnFloat,
+	"took":                  columnFloat,
+	"total_size":            columnInt,
+	"total_file_count":      columnInt,
+	"total_blob_count":      columnInt,
+	"date":                  columnDate,
+}
+
 // csvFileIterator is a type that can iterate over the records in a csv file
 type csvFileIterator struct {
 	filename          string
 	file              *os.File
 	reader            *csv.Reader
+	header            map[string]int
+	Version           int
 	CurrentLineNumber int
 	malformedLines    []int
 }
 
-// Open opens csv file at given filename for csvFileIterator it
-func (it *csvFileIterator) Open(filename string) {
+// Open opens csv file at given filename for csvFileIterator it. Files written
+// with a schema version start with a version line and a header, header-less
+// files are read using the legacy column order of given schema. A file that
+// does not exist has no records
+func (it *csvFileIterator) Open(filename string, schema *csvSchema) {
 	it.filename = filename
 
 	var err error
 	it.file, err = os.Open(filename)
 
+	if os.IsNotExist(err) {
+		return
+	}
 	if err != nil {
 		panic("failed to open csv file. " + err.Error())
 	}
 
-	it.reader = csv.NewReader(it.file)
+	buffered := bufio.NewReader(it.file)
+	it.Version = readSchemaVersion(buffered)
+	it.reader = csv.NewReader(buffered)
 	it.CurrentLineNumber = -1
-}
 
-// Close closes file descriptor used for reading csv by csvFileIterator it
-func (it *csvFileIterator) Close() {
-	if len(it.malformedLines) > 0 {
-		it.deleteMalformedLines()
+	columns := schema.LegacyColumns
+	if it.Version > 1 {
+		it.CurrentLineNumber++
+		columns = it.Next()
+	}
+
+	it.header = make(map[string]int)
+	for i, column := range columns {
+		it.header[column] = i
 	}
-	it.file.Close()
 }
 
-// deleteMalformedLines reads csv file and deletes the lines from the file, that
-// were found to be malformed, during iteration with the Next method
-func (it *csvFileIterator) deleteMalformedLines() {
-	data, err := ioutil.ReadFile(it.filename)
-	if err != nil {
-		panic(err)
+// readSchemaVersion consumes the version line from the start of given reader if
+// present and returns the schema version, files without a version line are version 1
+func readSchemaVersion(reader *bufio.Reader) int {
+	peek, _ := reader.Peek(len(schemaVersionPrefix))
+	if string(peek) != schemaVersionPrefix {
+		return 1
 	}
 
-	lines := strings.Split(string(data), "\n")
-	var out []string
+	line, _ := reader.ReadString('\n')
+	version, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, schemaVersionPrefix)))
+	if err != nil {
+		panic("invalid schema version line: " + line)
+	}
+	return version
+}
 
-outer:
-	for i, line := range lines {
-		for _, malformed := range it.malformedLines {
-			if i == malformed {
-				continue outer
-			}
-		}
-		out = append(out, line)
+// Get returns the value of given column in record, or an empty string if the
+// file read by csvFileIterator it has no such column
+func (it *csvFileIterator) Get(record []string, column string) string {
+	i, ok := it.header[column]
+	if !ok || i >= len(record) {
+		return ""
 	}
+	return record[i]
+}
 
-	cleaned := strings.Join(out, "\n")
-	ioutil.WriteFile(it.filename, []byte(cleaned), 0644)
+// Close closes file descriptor used for reading csv by csvFileIterator it.
+// Malformed lines are never removed from the file, they are only skipped
+// and reported, see Diagnose for a detailed report of problems in a file
+func (it *csvFileIterator) Close() {
+	if it.file == nil {
+		return
+	}
+	if len(it.malformedLines) > 0 {
+		fmt.Fprintf(
+			os.Stderr,
+			"%s: skipped %v malformed lines, run 'dfb stats doctor' for details\n",
+			it.filename,
+			len(it.malformedLines),
+		)
+	}
+	it.file.Close()
 }
 
 // Next reads and returns the next record from opened csv file by csvFileIterator it
 func (it *csvFileIterator) Next() []string {
+	if it.file == nil {
+		return nil
+	}
 	it.CurrentLineNumber++
 	line, err := it.reader.Read()
 	if err == io.EOF {
 		return nil
 	}
 
-	if err != nil {
-		if err, ok := err.(*csv.ParseError); ok && err.Err == csv.ErrFieldCount {
-			fmt.Printf("%s parse error at line %v\n", it.filename, it.CurrentLineNumber)
-			it.malformedLines = append(it.malformedLines, it.CurrentLineNumber)
-			return it.Next()
-		}
+	if _, ok := err.(*csv.ParseError); ok {
+		it.malformedLines = append(it.malformedLines, it.CurrentLineNumber)
+		return it.Next()
 	}
 
 	return line
@@ -92,23 +244,23 @@ func csvReadSummaries(filename string) []*SnapshotSummary {
 	var summaries []*SnapshotSummary
 
 	it := &csvFileIterator{}
-	it.Open(filename)
+	it.Open(filename, snapshotsSchema)
 	defer it.Close()
 
 	for record := it.Next(); record != nil; record = it.Next() {
-		filesNew, _ := strconv.Atoi(record[1])
-		filesChanged, _ := strconv.Atoi(record[2])
-		filesUnmodified, _ := strconv.Atoi(record[3])
-		dirsNew, _ := strconv.Atoi(record[4])
-		dirsChanged, _ := strconv.Atoi(record[5])
-		dirsUnmodified, _ := strconv.Atoi(record[6])
-		dataBlobs, _ := strconv.Atoi(record[7])
-		treeBlobs, _ := strconv.Atoi(record[8])
-		dataAdded, _ := strconv.Atoi(record[9])
-		totalFilesProcessed, _ := strconv.Atoi(record[10])
-		totalBytesProcessed, _ := strconv.Atoi(record[11])
-		totalDuration, _ := strconv.ParseFloat(record[12], 64)
-		date, _ := time.Parse("2006-01-02T15:04:05Z0700", record[17])
+		filesNew, _ := strconv.Atoi(it.Get(record, "files_new"))
+		filesChanged, _ := strconv.Atoi(it.Get(record, "files_changed"))
+		filesUnmodified, _ := strconv.Atoi(it.Get(record, "files_unmodified"))
+		dirsNew, _ := strconv.Atoi(it.Get(record, "dirs_new"))
+		dirsChanged, _ := strconv.Atoi(it.Get(record, "dirs_changed"))
+		dirsUnmodified, _ := strconv.Atoi(it.Get(record, "dirs_unmodified"))
+		dataBlobs, _ := strconv.Atoi(it.Get(record, "data_blobs"))
+		treeBlobs, _ := strconv.Atoi(it.Get(record, "tree_blobs"))
+		dataAdded, _ := strconv.Atoi(it.Get(record, "data_added"))
+		totalFilesProcessed, _ := strconv.Atoi(it.Get(record, "total_files_processed"))
+		totalBytesProcessed, _ := strconv.Atoi(it.Get(record, "total_bytes_processed"))
+		totalDuration, _ := strconv.ParseFloat(it.Get(record, "total_duration"), 64)
+		date, _ := time.Parse(csvDateLayout, it.Get(record, "date"))
 
 		summary := &SnapshotSummary{
 			FilesNew:            filesNew,
@@ -124,15 +276,21 @@ func csvReadSummaries(filename string) []*SnapshotSummary {
 			TotalBytesProcessed: totalBytesProcessed,
 			TotalDuration:       totalDuration,
 
-			SnapshotID: record[13],
-			Group:      record[14],
-			Domain:     record[15],
-			Repo:       record[16],
-
-			GroupWithWildcard:  []string{record[14], AllDomains},
-			DomainWithWildcard: []string{record[15], AllDomains},
+			SnapshotID: it.Get(record, "snapshot_id"),
+			Group:      it.Get(record, "group"),
+			Domain:     it.Get(record, "domain"),
+			Repo:       it.Get(record, "repo"),
+			Source:     recordSource(it.Get(record, "source")),
+
+			GroupWithWildcard:  []string{it.Get(record, "group"), AllGroups},
+			RepoWithWildcard:   []string{it.Get(record, "repo"), AllRepos},
+			DomainWithWildcard: []string{it.Get(record, "domain"), AllDomains},
+			Date:               date,
+			HourString:         dateKey(date, TimeUnitHours),
 			DateString:         date.Format(getDateLayoutForTimeUnit(TimeUnitDays)),
+			WeekString:         dateKey(date, TimeUnitWeeks),
 			MonthString:        date.Format(getDateLayoutForTimeUnit(TimeUnitMonths)),
+			QuarterString:      dateKey(date, TimeUnitQuarters),
 			YearString:         date.Format(getDateLayoutForTimeUnit(TimeUnitYears)),
 		}
 		summaries = append(summaries, summary)
@@ -146,24 +304,31 @@ func csvReadRepoBackupTime(filename string) []*RepoBackupTime {
 	var backupTimes []*RepoBackupTime
 
 	it := &csvFileIterator{}
-	it.Open(filename)
+	it.Open(filename, repoBackupTimeSchema)
 	defer it.Close()
 
 	for record := it.Next(); record != nil; record = it.Next() {
 
-		duration, _ := strconv.ParseFloat(record[0], 64)
-		date, _ := time.Parse("2006-01-02T15:04:05Z0700", record[3])
+		duration, _ := strconv.ParseFloat(it.Get(record, "took"), 64)
+		date, _ := time.Parse(csvDateLayout, it.Get(record, "date"))
 
 		bt := &RepoBackupTime{
 			Took: duration,
 
 			ID:    it.CurrentLineNumber,
-			Group: record[1],
-			Repo:  record[2],
-
-			DateString:  date.Format(getDateLayoutForTimeUnit(TimeUnitDays)),
-			MonthString: date.Format(getDateLayoutForTimeUnit(TimeUnitMonths)),
-			YearString:  date.Format(getDateLayoutForTimeUnit(TimeUnitYears)),
+			Group: it.Get(record, "group"),
+			Repo:  it.Get(record, "repo"),
+
+			GroupWithWildcard: []string{it.Get(record, "group"), AllGroups},
+			RepoWithWildcard:  []string{it.Get(record, "repo"), AllRepos},
+
+			Date:          date,
+			HourString:    dateKey(date, TimeUnitHours),
+			DateString:    date.Format(getDateLayoutForTimeUnit(TimeUnitDays)),
+			WeekString:    dateKey(date, TimeUnitWeeks),
+			MonthString:   date.Format(getDateLayoutForTimeUnit(TimeUnitMonths)),
+			QuarterString: dateKey(date, TimeUnitQuarters),
+			YearString:    date.Format(getDateLayoutForTimeUnit(TimeUnitYears)),
 		}
 		backupTimes = append(backupTimes, bt)
 	}
@@ -176,15 +341,15 @@ func csvReadRepoRawData(filename string) []*RepoRawData {
 	var rawData []*RepoRawData
 
 	it := &csvFileIterator{}
-	it.Open(filename)
+	it.Open(filename, repoRawDataSchema)
 	defer it.Close()
 
 	for record := it.Next(); record != nil; record = it.Next() {
 
-		totalSize, _ := strconv.ParseInt(record[0], 10, 64)
-		totalFileCount, _ := strconv.Atoi(record[1])
-		totalBlobCount, _ := strconv.Atoi(record[2])
-		date, _ := time.Parse("2006-01-02T15:04:05Z0700", record[5])
+		totalSize, _ := strconv.ParseInt(it.Get(record, "total_size"), 10, 64)
+		totalFileCount, _ := strconv.Atoi(it.Get(record, "total_file_count"))
+		totalBlobCount, _ := strconv.Atoi(it.Get(record, "total_blob_count"))
+		date, _ := time.Parse(csvDateLayout, it.Get(record, "date"))
 
 		rd := &RepoRawData{
 			TotalSize:      totalSize,
@@ -192,12 +357,19 @@ func csvReadRepoRawData(filename string) []*RepoRawData {
 			TotalBlobCount: totalBlobCount,
 
 			ID:    it.CurrentLineNumber,
-			Group: record[3],
-			Repo:  record[4],
-
-			DateString:  date.Format(getDateLayoutForTimeUnit(TimeUnitDays)),
-			MonthString: date.Format(getDateLayoutForTimeUnit(TimeUnitMonths)),
-			YearString:  date.Format(getDateLayoutForTimeUnit(TimeUnitYears)),
+			Group: it.Get(record, "group"),
+			Repo:  it.Get(record, "repo"),
+
+			GroupWithWildcard: []string{it.Get(record, "group"), AllGroups},
+			RepoWithWildcard:  []string{it.Get(record, "repo"), AllRepos},
+
+			Date:          date,
+			HourString:    dateKey(date, TimeUnitHours),
+			DateString:    date.Format(getDateLayoutForTimeUnit(TimeUnitDays)),
+			WeekString:    dateKey(date, TimeUnitWeeks),
+			MonthString:   date.Format(getDateLayoutForTimeUnit(TimeUnitMonths)),
+			QuarterString: dateKey(date, TimeUnitQuarters),
+			YearString:    date.Format(getDateLayoutForTimeUnit(TimeUnitYears)),
 		}
 		rawData = append(rawData, rd)
 	}
@@ -210,32 +382,39 @@ func csvReadDomainRawData(filename string) []*DomainRawData {
 	var rawData []*DomainRawData
 
 	it := &csvFileIterator{}
-	it.Open(filename)
+	it.Open(filename, domainRawDataSchema)
 	defer it.Close()
 
 	for record := it.Next(); record != nil; record = it.Next() {
 
-		totalSize, _ := strconv.ParseInt(record[0], 10, 64)
-		totalFileCount, _ := strconv.Atoi(record[1])
-		totalBlobCount, _ := strconv.Atoi(record[2])
-		date, _ := time.Parse("2006-01-02T15:04:05Z0700", record[6])
+		totalSize, _ := strconv.ParseInt(it.Get(record, "total_size"), 10, 64)
+		totalFileCount, _ := strconv.Atoi(it.Get(record, "total_file_count"))
+		totalBlobCount, _ := strconv.Atoi(it.Get(record, "total_blob_count"))
+		date, _ := time.Parse(csvDateLayout, it.Get(record, "date"))
 
 		rd := &DomainRawData{
 			TotalSize:      totalSize,
 			TotalFileCount: totalFileCount,
 			TotalBlobCount: totalBlobCount,
 
-			ID:     it.CurrentLineNumber,
-			Group:  record[3],
-			Domain: record[4],
-			Repo:   record[5],
-
-			GroupWithWildcard:  []string{record[3], AllDomains},
-			DomainWithWildcard: []string{record[4], AllDomains},
-
-			DateString:  date.Format(getDateLayoutForTimeUnit(TimeUnitDays)),
-			MonthString: date.Format(getDateLayoutForTimeUnit(TimeUnitMonths)),
-			YearString:  date.Format(getDateLayoutForTimeUnit(TimeUnitYears)),
+			ID:         it.CurrentLineNumber,
+			SnapshotID: it.Get(record, "snapshot_id"),
+			Group:      it.Get(record, "group"),
+			Domain:     it.Get(record, "domain"),
+			Repo:       it.Get(record, "repo"),
+			Source:     recordSource(it.Get(record, "source")),
+
+			GroupWithWildcard:  []string{it.Get(record, "group"), AllGroups},
+			RepoWithWildcard:   []string{it.Get(record, "repo"), AllRepos},
+			DomainWithWildcard: []string{it.Get(record, "domain"), AllDomains},
+
+			Date:          date,
+			HourString:    dateKey(date, TimeUnitHours),
+			DateString:    date.Format(getDateLayoutForTimeUnit(TimeUnitDays)),
+			WeekString:    dateKey(date, TimeUnitWeeks),
+			MonthString:   date.Format(getDateLayoutForTimeUnit(TimeUnitMonths)),
+			QuarterString: dateKey(date, TimeUnitQuarters),
+			YearString:    date.Format(getDateLayoutForTimeUnit(TimeUnitYears)),
 		}
 		rawData = append(rawData, rd)
 	}
@@ -248,33 +427,48 @@ func csvReadDomainRestoreSize(filename string) []*DomainRestoreSize {
 	var restoreSizes []*DomainRestoreSize
 
 	it := &csvFileIterator{}
-	it.Open(filename)
+	it.Open(filename, domainRestoreSizeSchema)
 	defer it.Close()
 
 	for record := it.Next(); record != nil; record = it.Next() {
 
-		totalSize, _ := strconv.ParseInt(record[0], 10, 64)
-		totalFileCount, _ := strconv.Atoi(record[1])
-		date, _ := time.Parse("2006-01-02T15:04:05Z0700", record[5])
+		totalSize, _ := strconv.ParseInt(it.Get(record, "total_size"), 10, 64)
+		totalFileCount, _ := strconv.Atoi(it.Get(record, "total_file_count"))
+		date, _ := time.Parse(csvDateLayout, it.Get(record, "date"))
 
 		rs := &DomainRestoreSize{
 			TotalSize:      totalSize,
 			TotalFileCount: totalFileCount,
 
-			ID:     it.CurrentLineNumber,
-			Group:  record[2],
-			Domain: record[3],
-			Repo:   record[4],
-
-			GroupWithWildcard:  []string{record[2], AllDomains},
-			DomainWithWildcard: []string{record[3], AllDomains},
-
-			DateString:  date.Format(getDateLayoutForTimeUnit(TimeUnitDays)),
-			MonthString: date.Format(getDateLayoutForTimeUnit(TimeUnitMonths)),
-			YearString:  date.Format(getDateLayoutForTimeUnit(TimeUnitYears)),
+			ID:         it.CurrentLineNumber,
+			SnapshotID: it.Get(record, "snapshot_id"),
+			Group:      it.Get(record, "group"),
+			Domain:     it.Get(record, "domain"),
+			Repo:       it.Get(record, "repo"),
+			Source:     recordSource(it.Get(record, "source")),
+
+			GroupWithWildcard:  []string{it.Get(record, "group"), AllGroups},
+			RepoWithWildcard:   []string{it.Get(record, "repo"), AllRepos},
+			DomainWithWildcard: []string{it.Get(record, "domain"), AllDomains},
+
+			Date:          date,
+			HourString:    dateKey(date, TimeUnitHours),
+			DateString:    date.Format(getDateLayoutForTimeUnit(TimeUnitDays)),
+			WeekString:    dateKey(date, TimeUnitWeeks),
+			MonthString:   date.Format(getDateLayoutForTimeUnit(TimeUnitMonths)),
+			QuarterString: dateKey(date, TimeUnitQuarters),
+			YearString:    date.Format(getDateLayoutForTimeUnit(TimeUnitYears)),
 		}
 		restoreSizes = append(restoreSizes, rs)
 	}
 
 	return restoreSizes
 }
+
+// recordSource returns the source of a row, rows without a source were recorded live
+func recordSource(source string) string {
+	if source == "" {
+		return SourceLive
+	}
+	return source
+}
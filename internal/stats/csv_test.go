@@ -0,0 +1,41 @@
+package stats
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestCsvFileIteratorSkipsMalformedLines(t *testing.T) {
+	file, err := ioutil.TempFile("", "dfb-stats-csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+
+	file.WriteString("#dfb-stats-schema:3\n" +
+		"took,group,repo,date\n" +
+		"10,demo,repo,2020-03-10T12:00:00+0100\n" +
+		"20,demo\n" +
+		"30,de\"mo,repo,2020-03-11T12:00:00+0100\n" +
+		"40,demo,repo,2020-03-12T12:00:00+0100\n" +
+		"50,\"demo,repo,2020-03-13T12:00:00+0100\n")
+	file.Close()
+
+	it := &csvFileIterator{}
+	it.Open(file.Name(), repoBackupTimeSchema)
+	defer it.Close()
+
+	var took []string
+	for record := it.Next(); record != nil; record = it.Next() {
+		took = append(took, it.Get(record, "took"))
+	}
+
+	if !reflect.DeepEqual(took, []string{"10", "40"}) {
+		t.Errorf("got records %v, want 10 and 40", took)
+	}
+	if !reflect.DeepEqual(it.malformedLines, []int{3, 4, 6}) {
+		t.Errorf("got malformed lines %v, want [3 4 6]", it.malformedLines)
+	}
+}
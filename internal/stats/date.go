@@ -1,6 +1,9 @@
 package stats
 
 import (
+	"errors"
+	"reflect"
+	"sort"
 	"strings"
 	"time"
 
@@ -8,31 +11,61 @@ import (
 )
 
 const (
+	// TimeUnitHours is an identifier that means a metrics values should be grouped by hour
+	TimeUnitHours = "hours"
+
 	// TimeUnitDays is an identifier that means a metrics values should be grouped by day
 	TimeUnitDays = "days"
 
+	// TimeUnitWeeks is an identifier that means a metrics values should be grouped by week,
+	// weeks start on monday
+	TimeUnitWeeks = "weeks"
+
 	// TimeUnitMonths is an identifier that means a metrics values should be grouped by month
 	TimeUnitMonths = "months"
 
+	// TimeUnitQuarters is an identifier that means a metrics values should be grouped by quarter
+	TimeUnitQuarters = "quarters"
+
 	// TimeUnitYears is an identifier that means a metrics values should be grouped by year
 	TimeUnitYears = "years"
 )
 
 // TimeUnits contains the supported time units
 var TimeUnits = []string{
+	TimeUnitHours,
 	TimeUnitDays,
+	TimeUnitWeeks,
 	TimeUnitMonths,
+	TimeUnitQuarters,
 	TimeUnitYears,
 }
 
-// getDateLayoutForTimeUnit returns the layout used for stringifying a time.Time for given timeUnit
+// ValidateTimeUnit returns an error if timeUnit is not a supported time unit
+func ValidateTimeUnit(timeUnit string) error {
+	for _, supported := range TimeUnits {
+		if timeUnit == supported {
+			return nil
+		}
+	}
+	return errors.New("unknown time unit " + timeUnit + ", availible time units are " + strings.Join(TimeUnits, ", "))
+}
+
+// getDateLayoutForTimeUnit returns the layout used for stringifying a time.Time for given timeUnit,
+// a week is labeled by the date of its monday and a quarter by its first month
 func getDateLayoutForTimeUnit(timeUnit string) string {
 	var layout string
 	switch strings.ToLower(timeUnit) {
+	case TimeUnitHours:
+		layout = "2006-01-02 15:00"
 	case TimeUnitDays:
 		layout = "2006-01-02"
+	case TimeUnitWeeks:
+		layout = "2006-01-02"
 	case TimeUnitMonths:
 		layout = "Jan 2006"
+	case TimeUnitQuarters:
+		layout = "Jan 2006"
 	case TimeUnitYears:
 		layout = "2006"
 	default:
@@ -41,6 +74,51 @@ func getDateLayoutForTimeUnit(timeUnit string) string {
 	return layout
 }
 
+// dateKey returns the string identifying the timeUnit date is in, as used in the
+// indices of the DB
+func dateKey(date time.Time, timeUnit string) string {
+	return startOfTimeUnit(date, timeUnit).Format(getDateLayoutForTimeUnit(timeUnit))
+}
+
+// startOfTimeUnit returns the start of the timeUnit date is in
+func startOfTimeUnit(date time.Time, timeUnit string) time.Time {
+	y, m, d := date.Date()
+	switch strings.ToLower(timeUnit) {
+	case TimeUnitHours:
+		return time.Date(y, m, d, date.Hour(), 0, 0, 0, date.Location())
+	case TimeUnitDays:
+		return time.Date(y, m, d, 0, 0, 0, 0, date.Location())
+	case TimeUnitWeeks:
+		return time.Date(y, m, d-(int(date.Weekday())+6)%7, 0, 0, 0, 0, date.Location())
+	case TimeUnitMonths:
+		return time.Date(y, m, 1, 0, 0, 0, 0, date.Location())
+	case TimeUnitQuarters:
+		return time.Date(y, m-(m-1)%3, 1, 0, 0, 0, 0, date.Location())
+	case TimeUnitYears:
+		return time.Date(y, 1, 1, 0, 0, 0, 0, date.Location())
+	}
+	panic("unsupported time unit: " + timeUnit)
+}
+
+// addTimeUnits returns date moved given amount of timeUnits, amount can be negative
+func addTimeUnits(date time.Time, timeUnit string, amount int) time.Time {
+	switch strings.ToLower(timeUnit) {
+	case TimeUnitHours:
+		return date.Add(time.Duration(amount) * time.Hour)
+	case TimeUnitDays:
+		return date.AddDate(0, 0, amount)
+	case TimeUnitWeeks:
+		return date.AddDate(0, 0, amount*7)
+	case TimeUnitMonths:
+		return date.AddDate(0, amount, 0)
+	case TimeUnitQuarters:
+		return date.AddDate(0, amount*3, 0)
+	case TimeUnitYears:
+		return date.AddDate(amount, 0, 0)
+	}
+	panic("unsupported time unit: " + timeUnit)
+}
+
 // DateIterator is a type used for iterating over a range of dates and querying
 // a DB for records for a given date
 type DateIterator struct {
@@ -49,6 +127,7 @@ type DateIterator struct {
 	CurrentOffset int
 
 	db          *DB
+	end         time.Time
 	currentDate *Date
 }
 
@@ -59,15 +138,40 @@ type Date struct {
 	Valid bool
 }
 
-// NewDateIterator returns a new date iterator
+// NewDateIterator returns a new date iterator over the last timeLength time
+// units before the current one, and the current one
 func NewDateIterator(db *DB, timeUnit string, timeLength int) DateIterator {
+	return newDateIterator(db, timeUnit, time.Now(), timeLength)
+}
+
+// NewDateIteratorForRange returns a new date iterator over the time units from
+// the one from is in, until the one before to. If from is zero, timeLength time
+// units before to are iterated over
+func NewDateIteratorForRange(db *DB, timeUnit string, from time.Time, to time.Time, timeLength int) DateIterator {
+	end := startOfTimeUnit(to.Add(-time.Nanosecond), timeUnit)
+	if from.IsZero() {
+		return newDateIterator(db, timeUnit, end, timeLength)
+	}
+
+	start := startOfTimeUnit(from, timeUnit)
+	length := 0
+	for addTimeUnits(start, timeUnit, length).Before(end) {
+		length++
+	}
+	return newDateIterator(db, timeUnit, end, length)
+}
+
+// newDateIterator returns a new date iterator over the timeLength time units
+// before the one end is in, and the one end is in
+func newDateIterator(db *DB, timeUnit string, end time.Time, timeLength int) DateIterator {
 	it := DateIterator{
 		TimeUnit:   timeUnit,
 		TimeLength: timeLength,
 		db:         db,
+		end:        startOfTimeUnit(end, timeUnit),
 	}
 	it.CurrentOffset = -1
-	it.currentDate = &Date{Value: time.Now(), Valid: true}
+	it.currentDate = &Date{Value: it.end, Valid: true}
 	it.decrementDate(timeLength + 1)
 	return it
 }
@@ -86,56 +190,227 @@ func (it *DateIterator) Next() *Date {
 // GetRecordsForDate queries the DB of DateIterator it for values in
 // provided table matching date and metric metadata and returns matching records
 func (it *DateIterator) GetRecordsForDate(table string, metric Metric, date time.Time) memdb.ResultIterator {
-	layout := getDateLayoutForTimeUnit(it.TimeUnit)
+	return it.getRecords(table, metric, metric.SupportsDomains(), metric.GetMetadata("domain"), date)
+}
+
+// GetRepoRecordsForDate queries the DB of DateIterator it for values in provided
+// table matching date and the repo and group of metric, for tables of records of
+// a whole repo when metric supports domains
+func (it *DateIterator) GetRepoRecordsForDate(table string, metric Metric, date time.Time) memdb.ResultIterator {
+	return it.getRecords(table, metric, false, "", date)
+}
 
+// GetRecordsForDateInAllDomains queries the DB of DateIterator it for values in
+// provided table matching date and the repo and group of metric, in any domain
+func (it *DateIterator) GetRecordsForDateInAllDomains(table string, metric Metric, date time.Time) memdb.ResultIterator {
+	return it.getRecords(table, metric, true, AllDomains, date)
+}
+
+// getRecords queries the DB of DateIterator it for values in provided table
+// matching date, the repo and group of metric and, if includeDomain, domain
+func (it *DateIterator) getRecords(table string, metric Metric, includeDomain bool, domain string, date time.Time) memdb.ResultIterator {
 	txn := it.db.memdb.Txn(false)
 	defer txn.Abort()
 
 	var args []interface{}
 	args = append(args, metric.GetMetadata("repo"))
 	args = append(args, metric.GetMetadata("group"))
-	if metric.SupportsDomains() {
-		args = append(args, metric.GetMetadata("domain"))
+	if includeDomain {
+		args = append(args, domain)
 	}
-	args = append(args, date.Format(layout))
+	args = append(args, dateKey(date, it.TimeUnit))
 
 	records, err := txn.Get(
 		table,
-		it.db.GetIndexFromTimeUnit(it.TimeUnit, metric.SupportsDomains()),
+		it.db.GetIndexFromTimeUnit(it.TimeUnit, includeDomain),
 		args...,
 	)
 	if err != nil {
 		panic("failed to fetch data from db for metric. " + err.Error())
 	}
 
+	return newChronologicalResults(records)
+}
+
+// GetLastRecordBefore queries the DB of DateIterator it for the latest record in
+// provided table matching metric metadata that is dated before the first date of
+// the iterator, nil is returned if there is no such record
+func (it *DateIterator) GetLastRecordBefore(table string, metric Metric) interface{} {
+	return it.lastRecordsBefore(table, func(v reflect.Value) bool {
+		return recordMatches(v, metric)
+	}, func(v reflect.Value) sourceKey {
+		return sourceKey{}
+	})[sourceKey{}]
+}
+
+// GetLastRecordsPerSourceBefore queries the DB of DateIterator it for the latest
+// record of each group, repo and domain in provided table matching metric
+// metadata that is dated before the first date of the iterator
+func (it *DateIterator) GetLastRecordsPerSourceBefore(table string, metric Metric) map[sourceKey]interface{} {
+	return it.lastRecordsBefore(table, func(v reflect.Value) bool {
+		return recordMatches(v, metric)
+	}, recordSourceKey)
+}
+
+// GetLastRecordsPerDomainBefore queries the DB of DateIterator it for the latest
+// record of each domain in provided table matching the repo and group of metric
+// that is dated before the first date of the iterator
+func (it *DateIterator) GetLastRecordsPerDomainBefore(table string, metric Metric) map[sourceKey]interface{} {
+	return it.lastRecordsBefore(table, func(v reflect.Value) bool {
+		return fieldMatches(v, "Repo", metric.GetMetadata("repo")) &&
+			fieldMatches(v, "Group", metric.GetMetadata("group"))
+	}, recordSourceKey)
+}
+
+// lastRecordsBefore returns the latest record in provided table that matches and
+// is dated before the first date of DateIterator it, per key of the records
+func (it *DateIterator) lastRecordsBefore(table string, matches func(v reflect.Value) bool, key func(v reflect.Value) sourceKey) map[sourceKey]interface{} {
+	start := it.firstDate()
+
+	txn := it.db.memdb.Txn(false)
+	defer txn.Abort()
+
+	records, err := txn.Get(table, "id")
+	if err != nil {
+		panic("failed to fetch data from db for metric. " + err.Error())
+	}
+
+	last := make(map[sourceKey]interface{})
+	for obj := records.Next(); obj != nil; obj = records.Next() {
+		if !recordDate(obj).Before(start) {
+			continue
+		}
+		v := reflect.Indirect(reflect.ValueOf(obj))
+		if !matches(v) {
+			continue
+		}
+		k := key(v)
+		if last[k] == nil || recordDate(obj).After(recordDate(last[k])) {
+			last[k] = obj
+		}
+	}
+	return last
+}
+
+// sourceKey identifies the group, repo and domain a record was collected for,
+// Domain is empty for records of a whole repo
+type sourceKey struct {
+	Group  string
+	Repo   string
+	Domain string
+}
+
+// recordSourceKey returns the sourceKey of the record in v
+func recordSourceKey(v reflect.Value) sourceKey {
+	key := sourceKey{Group: v.FieldByName("Group").String(), Repo: v.FieldByName("Repo").String()}
+	if domain := v.FieldByName("Domain"); domain.IsValid() {
+		key.Domain = domain.String()
+	}
+	return key
+}
+
+// keyOf returns the sourceKey of given record
+func keyOf(obj interface{}) sourceKey {
+	return recordSourceKey(reflect.Indirect(reflect.ValueOf(obj)))
+}
+
+// recordMatches returns whether the record in v matches the repo, group and,
+// if supported by both metric and record, domain of metric
+func recordMatches(v reflect.Value, metric Metric) bool {
+	if !fieldMatches(v, "Repo", metric.GetMetadata("repo")) {
+		return false
+	}
+	if !fieldMatches(v, "Group", metric.GetMetadata("group")) {
+		return false
+	}
+	hasDomain := v.FieldByName("Domain").IsValid()
+	if metric.SupportsDomains() && hasDomain && !fieldMatches(v, "Domain", metric.GetMetadata("domain")) {
+		return false
+	}
+	return true
+}
+
+// fieldMatches returns whether given field of the record in v matches value,
+// the wildcard variant of the field is used if the record has one set
+func fieldMatches(v reflect.Value, field string, value string) bool {
+	wildcard := v.FieldByName(field + "WithWildcard")
+	if !wildcard.IsValid() || wildcard.Len() == 0 {
+		return v.FieldByName(field).String() == value
+	}
+	for i := 0; i < wildcard.Len(); i++ {
+		if wildcard.Index(i).String() == value {
+			return true
+		}
+	}
+	return false
+}
+
+// firstDate returns the start of the first date DateIterator it iterates over
+func (it *DateIterator) firstDate() time.Time {
+	return addTimeUnits(it.end, it.TimeUnit, -it.TimeLength)
+}
+
+// chronologicalResults is a memdb.ResultIterator over records sorted by their
+// Date field, the order of records from memdb is the order of their index keys
+type chronologicalResults struct {
+	records []interface{}
+	watch   <-chan struct{}
+}
+
+// newChronologicalResults reads all records from results and returns an
+// iterator over them in chronological order
+func newChronologicalResults(results memdb.ResultIterator) *chronologicalResults {
+	it := &chronologicalResults{watch: results.WatchCh()}
+	for obj := results.Next(); obj != nil; obj = results.Next() {
+		it.records = append(it.records, obj)
+	}
+	sort.SliceStable(it.records, func(i, j int) bool {
+		return recordDate(it.records[i]).Before(recordDate(it.records[j]))
+	})
+	return it
+}
+
+// mergeChronologically reads all records from given results and returns them
+// in chronological order, records of several tables can be merged
+func mergeChronologically(results ...memdb.ResultIterator) []interface{} {
+	var records []interface{}
+	for _, result := range results {
+		for obj := result.Next(); obj != nil; obj = result.Next() {
+			records = append(records, obj)
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return recordDate(records[i]).Before(recordDate(records[j]))
+	})
 	return records
 }
 
+// WatchCh returns the watch channel of the underlying results
+func (it *chronologicalResults) WatchCh() <-chan struct{} {
+	return it.watch
+}
+
+// Next returns the next record, or nil when there are no more records
+func (it *chronologicalResults) Next() interface{} {
+	if len(it.records) == 0 {
+		return nil
+	}
+	obj := it.records[0]
+	it.records = it.records[1:]
+	return obj
+}
+
+// recordDate returns the Date field of a record in the DB
+func recordDate(obj interface{}) time.Time {
+	return reflect.Indirect(reflect.ValueOf(obj)).FieldByName("Date").Interface().(time.Time)
+}
+
 // incrementDate increments currentDate of DateIterator it by given amount
 func (it *DateIterator) incrementDate(amount int) {
-	switch strings.ToLower(it.TimeUnit) {
-	case TimeUnitDays:
-		it.currentDate.Value = it.currentDate.Value.AddDate(0, 0, amount)
-	case TimeUnitMonths:
-		it.currentDate.Value = it.currentDate.Value.AddDate(0, amount, 0)
-	case TimeUnitYears:
-		it.currentDate.Value = it.currentDate.Value.AddDate(amount, 0, 0)
-	default:
-		panic("unsupported time unit: " + it.TimeUnit)
-	}
+	it.currentDate.Value = addTimeUnits(it.currentDate.Value, it.TimeUnit, amount)
 }
 
 // decrementDate decrements currentDate of DateIterator it by given amount
 func (it *DateIterator) decrementDate(amount int) {
-	amount *= -1
-	switch strings.ToLower(it.TimeUnit) {
-	case TimeUnitDays:
-		it.currentDate.Value = it.currentDate.Value.AddDate(0, 0, amount)
-	case TimeUnitMonths:
-		it.currentDate.Value = it.currentDate.Value.AddDate(0, amount, 0)
-	case TimeUnitYears:
-		it.currentDate.Value = it.currentDate.Value.AddDate(amount, 0, 0)
-	default:
-		panic("unsupported time unit: " + it.TimeUnit)
-	}
+	it.currentDate.Value = addTimeUnits(it.currentDate.Value, it.TimeUnit, -amount)
 }
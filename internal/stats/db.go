@@ -3,8 +3,10 @@ package stats
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/go-memdb"
+	"github.com/nattvara/dfb/internal/groups"
 	"github.com/nattvara/dfb/internal/paths"
 )
 
@@ -16,12 +18,18 @@ type SnapshotSummary struct {
 	Group      string
 	Domain     string
 	Repo       string
+	Source     string
+	Date       time.Time
 
 	// Additional fields used for querying
 	GroupWithWildcard  []string
+	RepoWithWildcard   []string
 	DomainWithWildcard []string
+	HourString         string
 	DateString         string
+	WeekString         string
 	MonthString        string
+	QuarterString      string
 	YearString         string
 
 	// Values used for metrics
@@ -46,11 +54,16 @@ type RepoBackupTime struct {
 	ID    int
 	Group string
 	Repo  string
+	Date  time.Time
 
 	// Additional fields used for querying
 	GroupWithWildcard []string
+	RepoWithWildcard  []string
+	HourString        string
 	DateString        string
+	WeekString        string
 	MonthString       string
+	QuarterString     string
 	YearString        string
 
 	// Values used for metrics
@@ -64,11 +77,16 @@ type RepoRawData struct {
 	ID    int
 	Group string
 	Repo  string
+	Date  time.Time
 
 	// Additional fields used for querying
 	GroupWithWildcard []string
+	RepoWithWildcard  []string
+	HourString        string
 	DateString        string
+	WeekString        string
 	MonthString       string
+	QuarterString     string
 	YearString        string
 
 	// Values used for metrics
@@ -81,16 +99,23 @@ type RepoRawData struct {
 // by running the restic stats command with the raw-data mode on the latest snapshot
 type DomainRawData struct {
 	// Metadata
-	ID     int
-	Group  string
-	Domain string
-	Repo   string
+	ID         int
+	SnapshotID string
+	Group      string
+	Domain     string
+	Repo       string
+	Source     string
+	Date       time.Time
 
 	// Additional fields used for querying
 	GroupWithWildcard  []string
+	RepoWithWildcard   []string
 	DomainWithWildcard []string
+	HourString         string
 	DateString         string
+	WeekString         string
 	MonthString        string
+	QuarterString      string
 	YearString         string
 
 	// Values used for metrics
@@ -103,16 +128,23 @@ type DomainRawData struct {
 // by running the restic stats command with the restore-size mode on the latest snapshot
 type DomainRestoreSize struct {
 	// Metadata
-	ID     int
-	Group  string
-	Domain string
-	Repo   string
+	ID         int
+	SnapshotID string
+	Group      string
+	Domain     string
+	Repo       string
+	Source     string
+	Date       time.Time
 
 	// Additional fields used for querying
 	GroupWithWildcard  []string
+	RepoWithWildcard   []string
 	DomainWithWildcard []string
+	HourString         string
 	DateString         string
+	WeekString         string
 	MonthString        string
+	QuarterString      string
 	YearString         string
 
 	// Values used for metrics
@@ -126,30 +158,60 @@ type DomainRestoreSize struct {
 // memdb with backup data from csv files for a given group,
 // and retrieve object by querying various indices
 type DB struct {
-	memdb *memdb.MemDB
+	memdb  *memdb.MemDB
+	lastID int
+}
+
+// StatsDir returns the path to the stats directory of given group
+func StatsDir(groupName string) string {
+	return fmt.Sprintf("%s/%s/stats", paths.DFB(), groupName)
 }
 
-// Load loads db with data from csv files for given group
+// Load loads db with data from csv files for given group, or for every group
+// if given AllGroups
 func (db *DB) Load(groupName string) {
-	statsDir := fmt.Sprintf("%s/%s/stats", paths.DFB(), groupName)
+	if groupName != AllGroups {
+		db.loadGroup(groupName)
+		return
+	}
+	for _, group := range groups.FetchGroups() {
+		db.loadGroup(group.Name)
+	}
+}
 
-	for _, record := range csvReadSummaries(statsDir + "/snapshots.csv") {
+// loadGroup loads db with data from csv files for given group. Records are
+// given IDs unique to db, as the line numbers of files of several groups would
+// collide
+func (db *DB) loadGroup(groupName string) {
+	statsDir := StatsDir(groupName)
+
+	for _, record := range csvReadSummaries(statsDir + "/" + snapshotsSchema.Filename) {
 		db.InsertRecord("snapshot", record)
 	}
-	for _, record := range csvReadRepoBackupTime(statsDir + "/repo_time_took.csv") {
+	for _, record := range csvReadRepoBackupTime(statsDir + "/" + repoBackupTimeSchema.Filename) {
+		record.ID = db.nextID()
 		db.InsertRecord("repo_backup_times", record)
 	}
-	for _, record := range csvReadRepoRawData(statsDir + "/repo_raw_data.csv") {
+	for _, record := range csvReadRepoRawData(statsDir + "/" + repoRawDataSchema.Filename) {
+		record.ID = db.nextID()
 		db.InsertRecord("repo_raw_data", record)
 	}
-	for _, record := range csvReadDomainRawData(statsDir + "/domain_raw_data.csv") {
+	for _, record := range csvReadDomainRawData(statsDir + "/" + domainRawDataSchema.Filename) {
+		record.ID = db.nextID()
 		db.InsertRecord("domain_raw_data", record)
 	}
-	for _, record := range csvReadDomainRestoreSize(statsDir + "/domain_restore_size.csv") {
+	for _, record := range csvReadDomainRestoreSize(statsDir + "/" + domainRestoreSizeSchema.Filename) {
+		record.ID = db.nextID()
 		db.InsertRecord("domain_restore_size", record)
 	}
 }
 
+// nextID returns an ID that has not been given to any record in db
+func (db *DB) nextID() int {
+	db.lastID++
+	return db.lastID
+}
+
 // InsertRecord will insert a record in given table in memdb instance
 func (db *DB) InsertRecord(table string, record interface{}) {
 	txn := db.memdb.Txn(true)
@@ -159,6 +221,39 @@ func (db *DB) InsertRecord(table string, record interface{}) {
 	txn.Commit()
 }
 
+// GetSnapshotSummary returns the summary recorded for the snapshot with given
+// id, or nil if no summary was recorded. Summaries recorded with the short id
+// of the snapshot are found by its full id too
+func (db *DB) GetSnapshotSummary(snapshotID string) *SnapshotSummary {
+	txn := db.memdb.Txn(false)
+	defer txn.Abort()
+
+	for _, id := range snapshotIDs(snapshotID) {
+		obj, err := txn.First("snapshot", "id", id)
+		if err != nil {
+			panic("failed to fetch snapshot from db. " + err.Error())
+		}
+		if obj != nil {
+			return obj.(*SnapshotSummary)
+		}
+	}
+	return nil
+}
+
+// shortSnapshotIDLength is the length of the short id of a snapshot, as printed
+// by restic
+const shortSnapshotIDLength = 8
+
+// snapshotIDs returns the ids the stats of the snapshot with given id may be
+// recorded with, the id and the short id, as restic reports the short id in the
+// summary of a backup
+func snapshotIDs(snapshotID string) []string {
+	if len(snapshotID) <= shortSnapshotIDLength {
+		return []string{snapshotID}
+	}
+	return []string{snapshotID, snapshotID[:shortSnapshotIDLength]}
+}
+
 // GetIndexFromTimeUnit returns the index to use for given time unit, use includeDomain
 // to search for a specifc domain (not supported by all indices)
 func (db *DB) GetIndexFromTimeUnit(timeUnit string, includeDomain bool) string {
@@ -167,10 +262,16 @@ func (db *DB) GetIndexFromTimeUnit(timeUnit string, includeDomain bool) string {
 		index += "_domain"
 	}
 	switch strings.ToLower(timeUnit) {
+	case TimeUnitHours:
+		index += "_hourly"
 	case TimeUnitDays:
 		index += "_daily"
+	case TimeUnitWeeks:
+		index += "_weekly"
 	case TimeUnitMonths:
 		index += "_monthly"
+	case TimeUnitQuarters:
+		index += "_quarterly"
 	case TimeUnitYears:
 		index += "_yearly"
 	default:
@@ -191,39 +292,78 @@ func NewDB() *DB {
 						Unique:  true,
 						Indexer: &memdb.StringFieldIndex{Field: "SnapshotID"},
 					},
+					"repo_group_domain_hourly": &memdb.IndexSchema{
+						Name:   "repo_group_domain_hourly",
+						Unique: false,
+						Indexer: &memdb.CompoundMultiIndex{
+							AllowMissing: false,
+							Indexes: []memdb.Indexer{
+								&memdb.StringSliceFieldIndex{Field: "RepoWithWildcard"},
+								&memdb.StringSliceFieldIndex{Field: "GroupWithWildcard"},
+								&memdb.StringSliceFieldIndex{Field: "DomainWithWildcard"},
+								&memdb.StringFieldIndex{Field: "HourString"},
+							},
+						},
+					},
 					"repo_group_domain_daily": &memdb.IndexSchema{
 						Name:   "repo_group_domain_daily",
 						Unique: false,
 						Indexer: &memdb.CompoundMultiIndex{
 							AllowMissing: false,
 							Indexes: []memdb.Indexer{
-								&memdb.StringFieldIndex{Field: "Repo"},
+								&memdb.StringSliceFieldIndex{Field: "RepoWithWildcard"},
 								&memdb.StringSliceFieldIndex{Field: "GroupWithWildcard"},
 								&memdb.StringSliceFieldIndex{Field: "DomainWithWildcard"},
 								&memdb.StringFieldIndex{Field: "DateString"},
 							},
 						},
 					},
+					"repo_group_domain_weekly": &memdb.IndexSchema{
+						Name:   "repo_group_domain_weekly",
+						Unique: false,
+						Indexer: &memdb.CompoundMultiIndex{
+							AllowMissing: false,
+							Indexes: []memdb.Indexer{
+								&memdb.StringSliceFieldIndex{Field: "RepoWithWildcard"},
+								&memdb.StringSliceFieldIndex{Field: "GroupWithWildcard"},
+								&memdb.StringSliceFieldIndex{Field: "DomainWithWildcard"},
+								&memdb.StringFieldIndex{Field: "WeekString"},
+							},
+						},
+					},
 					"repo_group_domain_monthly": &memdb.IndexSchema{
 						Name:   "repo_group_domain_monthly",
 						Unique: false,
 						Indexer: &memdb.CompoundMultiIndex{
 							AllowMissing: false,
 							Indexes: []memdb.Indexer{
-								&memdb.StringFieldIndex{Field: "Repo"},
+								&memdb.StringSliceFieldIndex{Field: "RepoWithWildcard"},
 								&memdb.StringSliceFieldIndex{Field: "GroupWithWildcard"},
 								&memdb.StringSliceFieldIndex{Field: "DomainWithWildcard"},
 								&memdb.StringFieldIndex{Field: "MonthString"},
 							},
 						},
 					},
+					"repo_group_domain_quarterly": &memdb.IndexSchema{
+						Name:   "repo_group_domain_quarterly",
+						Unique: false,
+						Indexer: &memdb.CompoundMultiIndex{
+							AllowMissing: false,
+							Indexes: []memdb.Indexer{
+								&memdb.StringSliceFieldIndex{Field: "RepoWithWildcard"},
+								&memdb.StringSliceFieldIndex{Field: "GroupWithWildcard"},
+								&memdb.StringSliceFieldIndex{Field: "DomainWithWildcard"},
+								&memdb.StringFieldIndex{Field: "QuarterString"},
+							},
+						},
+					},
 					"repo_group_domain_yearly": &memdb.IndexSchema{
 						Name:   "repo_group_domain_yearly",
 						Unique: false,
 						Indexer: &memdb.CompoundMultiIndex{
 							AllowMissing: false,
 							Indexes: []memdb.Indexer{
-								&memdb.StringFieldIndex{Field: "Repo"},
+								&memdb.StringSliceFieldIndex{Field: "RepoWithWildcard"},
 								&memdb.StringSliceFieldIndex{Field: "GroupWithWildcard"},
 								&memdb.StringSliceFieldIndex{Field: "DomainWithWildcard"},
 								&memdb.StringFieldIndex{Field: "YearString"},
@@ -240,38 +380,74 @@ func NewDB() *DB {
 						Unique:  true,
 						Indexer: &memdb.IntFieldIndex{Field: "ID"},
 					},
+					"repo_group_hourly": &memdb.IndexSchema{
+						Name:   "repo_group_hourly",
+						Unique: false,
+						Indexer: &memdb.CompoundMultiIndex{
+							AllowMissing: false,
+							Indexes: []memdb.Indexer{
+								&memdb.StringSliceFieldIndex{Field: "RepoWithWildcard"},
+								&memdb.StringSliceFieldIndex{Field: "GroupWithWildcard"},
+								&memdb.StringFieldIndex{Field: "HourString"},
+							},
+						},
+					},
 					"repo_group_daily": &memdb.IndexSchema{
 						Name:   "repo_group_daily",
 						Unique: false,
 						Indexer: &memdb.CompoundMultiIndex{
 							AllowMissing: false,
 							Indexes: []memdb.Indexer{
-								&memdb.StringFieldIndex{Field: "Repo"},
-								&memdb.StringFieldIndex{Field: "Group"},
+								&memdb.StringSliceFieldIndex{Field: "RepoWithWildcard"},
+								&memdb.StringSliceFieldIndex{Field: "GroupWithWildcard"},
 								&memdb.StringFieldIndex{Field: "DateString"},
 							},
 						},
 					},
+					"repo_group_weekly": &memdb.IndexSchema{
+						Name:   "repo_group_weekly",
+						Unique: false,
+						Indexer: &memdb.CompoundMultiIndex{
+							AllowMissing: false,
+							Indexes: []memdb.Indexer{
+								&memdb.StringSliceFieldIndex{Field: "RepoWithWildcard"},
+								&memdb.StringSliceFieldIndex{Field: "GroupWithWildcard"},
+								&memdb.StringFieldIndex{Field: "WeekString"},
+							},
+						},
+					},
 					"repo_group_monthly": &memdb.IndexSchema{
 						Name:   "repo_group_monthly",
 						Unique: false,
 						Indexer: &memdb.CompoundMultiIndex{
 							AllowMissing: false,
 							Indexes: []memdb.Indexer{
-								&memdb.StringFieldIndex{Field: "Repo"},
-								&memdb.StringFieldIndex{Field: "Group"},
+								&memdb.StringSliceFieldIndex{Field: "RepoWithWildcard"},
+								&memdb.StringSliceFieldIndex{Field: "GroupWithWildcard"},
 								&memdb.StringFieldIndex{Field: "MonthString"},
 							},
 						},
 					},
+					"repo_group_quarterly": &memdb.IndexSchema{
+						Name:   "repo_group_quarterly",
+						Unique: false,
+						Indexer: &memdb.CompoundMultiIndex{
+							AllowMissing: false,
+							Indexes: []memdb.Indexer{
+								&memdb.StringSliceFieldIndex{Field: "RepoWithWildcard"},
+								&memdb.StringSliceFieldIndex{Field: "GroupWithWildcard"},
+								&memdb.StringFieldIndex{Field: "QuarterString"},
+							},
+						},
+					},
 					"repo_group_yearly": &memdb.IndexSchema{
 						Name:   "repo_group_yearly",
 						Unique: false,
 						Indexer: &memdb.CompoundMultiIndex{
 							AllowMissing: false,
 							Indexes: []memdb.Indexer{
-								&memdb.StringFieldIndex{Field: "Repo"},
-								&memdb.StringFieldIndex{Field: "Group"},
+								&memdb.StringSliceFieldIndex{Field: "RepoWithWildcard"},
+								&memdb.StringSliceFieldIndex{Field: "GroupWithWildcard"},
 								&memdb.StringFieldIndex{Field: "YearString"},
 							},
 						},
@@ -286,38 +462,74 @@ func NewDB() *DB {
 						Unique:  true,
 						Indexer: &memdb.IntFieldIndex{Field: "ID"},
 					},
+					"repo_group_hourly": &memdb.IndexSchema{
+						Name:   "repo_group_hourly",
+						Unique: false,
+						Indexer: &memdb.CompoundMultiIndex{
+							AllowMissing: false,
+							Indexes: []memdb.Indexer{
+								&memdb.StringSliceFieldIndex{Field: "RepoWithWildcard"},
+								&memdb.StringSliceFieldIndex{Field: "GroupWithWildcard"},
+								&memdb.StringFieldIndex{Field: "HourString"},
+							},
+						},
+					},
 					"repo_group_daily": &memdb.IndexSchema{
 						Name:   "repo_group_daily",
 						Unique: false,
 						Indexer: &memdb.CompoundMultiIndex{
 							AllowMissing: false,
 							Indexes: []memdb.Indexer{
-								&memdb.StringFieldIndex{Field: "Repo"},
-								&memdb.StringFieldIndex{Field: "Group"},
+								&memdb.StringSliceFieldIndex{Field: "RepoWithWildcard"},
+								&memdb.StringSliceFieldIndex{Field: "GroupWithWildcard"},
 								&memdb.StringFieldIndex{Field: "DateString"},
 							},
 						},
 					},
+					"repo_group_weekly": &memdb.IndexSchema{
+						Name:   "repo_group_weekly",
+						Unique: false,
+						Indexer: &memdb.CompoundMultiIndex{
+							AllowMissing: false,
+							Indexes: []memdb.Indexer{
+								&memdb.StringSliceFieldIndex{Field: "RepoWithWildcard"},
+								&memdb.StringSliceFieldIndex{Field: "GroupWithWildcard"},
+								&memdb.StringFieldIndex{Field: "WeekString"},
+							},
+						},
+					},
 					"repo_group_monthly": &memdb.IndexSchema{
 						Name:   "repo_group_monthly",
 						Unique: false,
 						Indexer: &memdb.CompoundMultiIndex{
 							AllowMissing: false,
 							Indexes: []memdb.Indexer{
-								&memdb.StringFieldIndex{Field: "Repo"},
-								&memdb.StringFieldIndex{Field: "Group"},
+								&memdb.StringSliceFieldIndex{Field: "RepoWithWildcard"},
+								&memdb.StringSliceFieldIndex{Field: "GroupWithWildcard"},
 								&memdb.StringFieldIndex{Field: "MonthString"},
 							},
 						},
 					},
+					"repo_group_quarterly": &memdb.IndexSchema{
+						Name:   "repo_group_quarterly",
+						Unique: false,
+						Indexer: &memdb.CompoundMultiIndex{
+							AllowMissing: false,
+							Indexes: []memdb.Indexer{
+								&memdb.StringSliceFieldIndex{Field: "RepoWithWildcard"},
+								&memdb.StringSliceFieldIndex{Field: "GroupWithWildcard"},
+								&memdb.StringFieldIndex{Field: "QuarterString"},
+							},
+						},
+					},
 					"repo_group_yearly": &memdb.IndexSchema{
 						Name:   "repo_group_yearly",
 						Unique: false,
 						Indexer: &memdb.CompoundMultiIndex{
 							AllowMissing: false,
 							Indexes: []memdb.Indexer{
-								&memdb.StringFieldIndex{Field: "Repo"},
-								&memdb.StringFieldIndex{Field: "Group"},
+								&memdb.StringSliceFieldIndex{Field: "RepoWithWildcard"},
+								&memdb.StringSliceFieldIndex{Field: "GroupWithWildcard"},
 								&memdb.StringFieldIndex{Field: "YearString"},
 							},
 						},
@@ -332,39 +544,78 @@ func NewDB() *DB {
 						Unique:  true,
 						Indexer: &memdb.IntFieldIndex{Field: "ID"},
 					},
+					"repo_group_domain_hourly": &memdb.IndexSchema{
+						Name:   "repo_group_domain_hourly",
+						Unique: false,
+						Indexer: &memdb.CompoundMultiIndex{
+							AllowMissing: false,
+							Indexes: []memdb.Indexer{
+								&memdb.StringSliceFieldIndex{Field: "RepoWithWildcard"},
+								&memdb.StringSliceFieldIndex{Field: "GroupWithWildcard"},
+								&memdb.StringSliceFieldIndex{Field: "DomainWithWildcard"},
+								&memdb.StringFieldIndex{Field: "HourString"},
+							},
+						},
+					},
 					"repo_group_domain_daily": &memdb.IndexSchema{
 						Name:   "repo_group_domain_daily",
 						Unique: false,
 						Indexer: &memdb.CompoundMultiIndex{
 							AllowMissing: false,
 							Indexes: []memdb.Indexer{
-								&memdb.StringFieldIndex{Field: "Repo"},
+								&memdb.StringSliceFieldIndex{Field: "RepoWithWildcard"},
 								&memdb.StringSliceFieldIndex{Field: "GroupWithWildcard"},
 								&memdb.StringSliceFieldIndex{Field: "DomainWithWildcard"},
 								&memdb.StringFieldIndex{Field: "DateString"},
 							},
 						},
 					},
+					"repo_group_domain_weekly": &memdb.IndexSchema{
+						Name:   "repo_group_domain_weekly",
+						Unique: false,
+						Indexer: &memdb.CompoundMultiIndex{
+							AllowMissing: false,
+							Indexes: []memdb.Indexer{
+								&memdb.StringSliceFieldIndex{Field: "RepoWithWildcard"},
+								&memdb.StringSliceFieldIndex{Field: "GroupWithWildcard"},
+								&memdb.StringSliceFieldIndex{Field: "DomainWithWildcard"},
+								&memdb.StringFieldIndex{Field: "WeekString"},
+							},
+						},
+					},
 					"repo_group_domain_monthly": &memdb.IndexSchema{
 						Name:   "repo_group_domain_monthly",
 						Unique: false,
 						Indexer: &memdb.CompoundMultiIndex{
 							AllowMissing: false,
 							Indexes: []memdb.Indexer{
-								&memdb.StringFieldIndex{Field: "Repo"},
+								&memdb.StringSliceFieldIndex{Field: "RepoWithWildcard"},
 								&memdb.StringSliceFieldIndex{Field: "GroupWithWildcard"},
 								&memdb.StringSliceFieldIndex{Field: "DomainWithWildcard"},
 								&memdb.StringFieldIndex{Field: "MonthString"},
 							},
 						},
 					},
+					"repo_group_domain_quarterly": &memdb.IndexSchema{
+						Name:   "repo_group_domain_quarterly",
+						Unique: false,
+						Indexer: &memdb.CompoundMultiIndex{
+							AllowMissing: false,
+							Indexes: []memdb.Indexer{
+								&memdb.StringSliceFieldIndex{Field: "RepoWithWildcard"},
+								&memdb.StringSliceFieldIndex{Field: "GroupWithWildcard"},
+								&memdb.StringSliceFieldIndex{Field: "DomainWithWildcard"},
+								&memdb.StringFieldIndex{Field: "QuarterString"},
+							},
+						},
+					},
 					"repo_group_domain_yearly": &memdb.IndexSchema{
 						Name:   "repo_group_domain_yearly",
 						Unique: false,
 						Indexer: &memdb.CompoundMultiIndex{
 							AllowMissing: false,
 							Indexes: []memdb.Indexer{
-								&memdb.StringFieldIndex{Field: "Repo"},
+								&memdb.StringSliceFieldIndex{Field: "RepoWithWildcard"},
 								&memdb.StringSliceFieldIndex{Field: "GroupWithWildcard"},
 								&memdb.StringSliceFieldIndex{Field: "DomainWithWildcard"},
 								&memdb.StringFieldIndex{Field: "YearString"},
@@ -381,39 +632,78 @@ func NewDB() *DB {
 						Unique:  true,
 						Indexer: &memdb.IntFieldIndex{Field: "ID"},
 					},
+					"repo_group_domain_hourly": &memdb.IndexSchema{
+						Name:   "repo_group_domain_hourly",
+						Unique: false,
+						Indexer: &memdb.CompoundMultiIndex{
+							AllowMissing: false,
+							Indexes: []memdb.Indexer{
+								&memdb.StringSliceFieldIndex{Field: "RepoWithWildcard"},
+								&memdb.StringSliceFieldIndex{Field: "GroupWithWildcard"},
+								&memdb.StringSliceFieldIndex{Field: "DomainWithWildcard"},
+								&memdb.StringFieldIndex{Field: "HourString"},
+							},
+						},
+					},
 					"repo_group_domain_daily": &memdb.IndexSchema{
 						Name:   "repo_group_domain_daily",
 						Unique: false,
 						Indexer: &memdb.CompoundMultiIndex{
 							AllowMissing: false,
 							Indexes: []memdb.Indexer{
-								&memdb.StringFieldIndex{Field: "Repo"},
+								&memdb.StringSliceFieldIndex{Field: "RepoWithWildcard"},
 								&memdb.StringSliceFieldIndex{Field: "GroupWithWildcard"},
 								&memdb.StringSliceFieldIndex{Field: "DomainWithWildcard"},
 								&memdb.StringFieldIndex{Field: "DateString"},
 							},
 						},
 					},
+					"repo_group_domain_weekly": &memdb.IndexSchema{
+						Name:   "repo_group_domain_weekly",
+						Unique: false,
+						Indexer: &memdb.CompoundMultiIndex{
+							AllowMissing: false,
+							Indexes: []memdb.Indexer{
+								&memdb.StringSliceFieldIndex{Field: "RepoWithWildcard"},
+								&memdb.StringSliceFieldIndex{Field: "GroupWithWildcard"},
+								&memdb.StringSliceFieldIndex{Field: "DomainWithWildcard"},
+								&memdb.StringFieldIndex{Field: "WeekString"},
+							},
+						},
+					},
 					"repo_group_domain_monthly": &memdb.IndexSchema{
 						Name:   "repo_group_domain_monthly",
 						Unique: false,
 						Indexer: &memdb.CompoundMultiIndex{
 							AllowMissing: false,
 							Indexes: []memdb.Indexer{
-								&memdb.StringFieldIndex{Field: "Repo"},
+								&memdb.StringSliceFieldIndex{Field: "RepoWithWildcard"},
 								&memdb.StringSliceFieldIndex{Field: "GroupWithWildcard"},
 								&memdb.StringSliceFieldIndex{Field: "DomainWithWildcard"},
 								&memdb.StringFieldIndex{Field: "MonthString"},
 							},
 						},
 					},
+					"repo_group_domain_quarterly": &memdb.IndexSchema{
+						Name:   "repo_group_domain_quarterly",
+						Unique: false,
+						Indexer: &memdb.CompoundMultiIndex{
+							AllowMissing: false,
+							Indexes: []memdb.Indexer{
+								&memdb.StringSliceFieldIndex{Field: "RepoWithWildcard"},
+								&memdb.StringSliceFieldIndex{Field: "GroupWithWildcard"},
+								&memdb.StringSliceFieldIndex{Field: "DomainWithWildcard"},
+								&memdb.StringFieldIndex{Field: "QuarterString"},
+							},
+						},
+					},
 					"repo_group_domain_yearly": &memdb.IndexSchema{
 						Name:   "repo_group_domain_yearly",
 						Unique: false,
 						Indexer: &memdb.CompoundMultiIndex{
 							AllowMissing: false,
 							Indexes: []memdb.Indexer{
-								&memdb.StringFieldIndex{Field: "Repo"},
+								&memdb.StringSliceFieldIndex{Field: "RepoWithWildcard"},
 								&memdb.StringSliceFieldIndex{Field: "GroupWithWildcard"},
 								&memdb.StringSliceFieldIndex{Field: "DomainWithWildcard"},
 								&memdb.StringFieldIndex{Field: "YearString"},
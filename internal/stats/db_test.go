@@ -0,0 +1,34 @@
+package stats
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetSnapshotSummaryFindsShortID(t *testing.T) {
+	date := time.Date(2020, 3, 10, 12, 0, 0, 0, time.Local)
+
+	db := NewDB()
+	insertSnapshot(db, "3f2c8a91", SourceLive, date, 100, 60)
+	insertSnapshot(db, "9b7d1e04c5a2f3b6d8e9f0a1b2c3d4e5f6a7b8c9d0e1f2a3b4c5d6e7f8a9b0c1", SourceBackfill, date, 0, 0)
+
+	tests := map[string]string{
+		"3f2c8a91": "3f2c8a91",
+		"3f2c8a91d4e5f6a7b8c9d0e1f2a3b4c5d6e7f8a9b0c1d2e3f4a5b6c7d8e9f0a1": "3f2c8a91",
+		"9b7d1e04c5a2f3b6d8e9f0a1b2c3d4e5f6a7b8c9d0e1f2a3b4c5d6e7f8a9b0c1": "9b7d1e04c5a2f3b6d8e9f0a1b2c3d4e5f6a7b8c9d0e1f2a3b4c5d6e7f8a9b0c1",
+	}
+	for id, want := range tests {
+		summary := db.GetSnapshotSummary(id)
+		if summary == nil {
+			t.Errorf("%s: got no summary, want %s", id, want)
+			continue
+		}
+		if summary.SnapshotID != want {
+			t.Errorf("%s: got summary %s, want %s", id, summary.SnapshotID, want)
+		}
+	}
+
+	if summary := db.GetSnapshotSummary("0000000011111111"); summary != nil {
+		t.Errorf("got summary %s for unknown snapshot, want none", summary.SnapshotID)
+	}
+}
@@ -0,0 +1,209 @@
+package stats
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nattvara/dfb/internal/paths"
+)
+
+const (
+	// ProblemMalformedRow means a row has the wrong number of fields or is not valid csv
+	ProblemMalformedRow = "malformed row"
+
+	// ProblemInvalidNumber means a numeric column of a row could not be parsed
+	ProblemInvalidNumber = "invalid number"
+
+	// ProblemInvalidDate means the date column of a row could not be parsed
+	ProblemInvalidDate = "invalid date"
+
+	// ProblemDuplicateSnapshot means a snapshot id was already recorded on an earlier row
+	ProblemDuplicateSnapshot = "duplicate snapshot"
+)
+
+// Problem is an issue found on a line in one of the stats files
+type Problem struct {
+	Path    string
+	Line    int
+	Kind    string
+	Message string
+}
+
+// String returns a string representation of Problem p
+func (p Problem) String() string {
+	return fmt.Sprintf("%s:%v %s: %s", p.Path, p.Line, p.Kind, p.Message)
+}
+
+// Diagnose scans every stats file in given stats directory and returns the
+// problems found, the files are not modified
+func Diagnose(statsDir string) ([]Problem, error) {
+	var problems []Problem
+
+	for _, schema := range csvSchemas {
+		path := statsDir + "/" + schema.Filename
+		if !paths.Exists(path) {
+			continue
+		}
+
+		found, err := diagnoseFile(path, schema)
+		if err != nil {
+			return problems, err
+		}
+		problems = append(problems, found...)
+	}
+
+	return problems, nil
+}
+
+// diagnoseFile returns the problems found in the stats file at given path
+func diagnoseFile(path string, schema *csvSchema) ([]Problem, error) {
+	var problems []Problem
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return problems, err
+	}
+
+	lines := strings.Split(string(data), "\n")
+	columns := schema.LegacyColumns
+	start := 0
+	if len(lines) > 0 && strings.HasPrefix(lines[0], schemaVersionPrefix) {
+		if len(lines) < 2 {
+			return problems, nil
+		}
+		columns = parseLine(lines[1])
+		start = 2
+	}
+
+	seenSnapshots := make(map[string]int)
+
+	for i := start; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == "" {
+			continue
+		}
+
+		problem := Problem{Path: path, Line: i + 1}
+		record := parseLine(lines[i])
+		if len(record) != len(columns) {
+			problem.Kind = ProblemMalformedRow
+			problem.Message = fmt.Sprintf("expected %v fields, found %v", len(columns), len(record))
+			problems = append(problems, problem)
+			continue
+		}
+
+		for j, column := range columns {
+			if msg := validateValue(column, record[j]); msg != "" {
+				problem.Message = msg
+				problem.Kind = ProblemInvalidNumber
+				if columnKinds[column] == columnDate {
+					problem.Kind = ProblemInvalidDate
+				}
+				problems = append(problems, problem)
+			}
+
+			if column != "snapshot_id" || record[j] == "" {
+				continue
+			}
+			if first, ok := seenSnapshots[record[j]]; ok {
+				problem.Kind = ProblemDuplicateSnapshot
+				problem.Message = fmt.Sprintf("snapshot %s was first recorded on line %v", record[j], first)
+				problems = append(problems, problem)
+			} else {
+				seenSnapshots[record[j]] = i + 1
+			}
+		}
+	}
+
+	return problems, nil
+}
+
+// parseLine parses a single line of csv, returns nil if line is not valid csv
+func parseLine(line string) []string {
+	reader := csv.NewReader(strings.NewReader(line))
+	reader.FieldsPerRecord = -1
+	record, err := reader.Read()
+	if err != nil {
+		return nil
+	}
+	return record
+}
+
+// validateValue validates value of given column, returns a description of
+// the problem or an empty string if the value is valid
+func validateValue(column string, value string) string {
+	var err error
+	switch columnKinds[column] {
+	case columnInt:
+		_, err = strconv.ParseInt(value, 10, 64)
+	case columnFloat:
+		_, err = strconv.ParseFloat(value, 64)
+	case columnDate:
+		_, err = time.Parse(csvDateLayout, value)
+	}
+	if err != nil {
+		return fmt.Sprintf("column %s: %s", column, err.Error())
+	}
+	return ""
+}
+
+// Quarantine moves the lines of given problems out of their stats files and
+// appends them to a .rejected file next to the stats file. Returns the
+// number of lines moved
+func Quarantine(problems []Problem) (int, error) {
+	lines := make(map[string]map[int]bool)
+	for _, problem := range problems {
+		if _, ok := lines[problem.Path]; !ok {
+			lines[problem.Path] = make(map[int]bool)
+		}
+		lines[problem.Path][problem.Line] = true
+	}
+
+	var moved int
+	for path, rejected := range lines {
+		count, err := quarantineLines(path, rejected)
+		moved += count
+		if err != nil {
+			return moved, fmt.Errorf("failed to quarantine lines of %s. %s", path, err.Error())
+		}
+	}
+	return moved, nil
+}
+
+// quarantineLines moves given line numbers (starting at 1) from the file at
+// given path to its .rejected file
+func quarantineLines(path string, rejected map[int]bool) (int, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var kept []string
+	var moved []string
+	for i, line := range strings.Split(string(data), "\n") {
+		if rejected[i+1] {
+			moved = append(moved, line)
+			continue
+		}
+		kept = append(kept, line)
+	}
+
+	file, err := os.OpenFile(path+".rejected", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	if _, err := file.WriteString(strings.Join(moved, "\n") + "\n"); err != nil {
+		return 0, err
+	}
+
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(strings.Join(kept, "\n")), 0644); err != nil {
+		return 0, err
+	}
+	return len(moved), os.Rename(tmp, path)
+}
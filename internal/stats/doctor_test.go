@@ -0,0 +1,123 @@
+package stats
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// snapshotRow returns a valid row of snapshots.csv of given snapshot, with the
+// values of given columns replaced
+func snapshotRow(snapshotID string, replace map[string]string) string {
+	var values []string
+	for _, column := range snapshotsSchema.Columns {
+		value := "1"
+		switch column {
+		case "snapshot_id":
+			value = snapshotID
+		case "total_duration":
+			value = "1.5"
+		case "group", "domain", "repo":
+			value = "demo"
+		case "date":
+			value = "2020-03-10T12:00:00+0100"
+		case "source":
+			value = SourceLive
+		}
+		if v, ok := replace[column]; ok {
+			value = v
+		}
+		values = append(values, value)
+	}
+	return strings.Join(values, ",")
+}
+
+// writeSnapshotsFile writes given rows to snapshots.csv of the current schema
+// version in a new stats directory, returns the directory and the file path
+func writeSnapshotsFile(t *testing.T, rows ...string) (string, string) {
+	dir, err := ioutil.TempDir("", "dfb-stats-doctor")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, snapshotsSchema.Filename)
+	content := "#dfb-stats-schema:3\n" + strings.Join(snapshotsSchema.Columns, ",") + "\n" + strings.Join(rows, "\n") + "\n"
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir, path
+}
+
+func TestDiagnose(t *testing.T) {
+	tests := []struct {
+		name string
+		row  string
+		kind string
+	}{
+		{"bad int", snapshotRow("b", map[string]string{"files_new": "many"}), ProblemInvalidNumber},
+		{"bad float", snapshotRow("b", map[string]string{"total_duration": "1.5s"}), ProblemInvalidNumber},
+		{"bad date", snapshotRow("b", map[string]string{"date": "2020-03-10"}), ProblemInvalidDate},
+		{"duplicate snapshot", snapshotRow("a", nil), ProblemDuplicateSnapshot},
+		{"wrong field count", "b,1,2", ProblemMalformedRow},
+	}
+	for _, tt := range tests {
+		dir, path := writeSnapshotsFile(t, snapshotRow("a", nil), tt.row)
+		defer os.RemoveAll(dir)
+
+		problems, err := Diagnose(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := []Problem{{Path: path, Line: 4, Kind: tt.kind}}
+		for i := range problems {
+			problems[i].Message = ""
+		}
+		if !reflect.DeepEqual(problems, want) {
+			t.Errorf("%s: got problems %v, want %v", tt.name, problems, want)
+		}
+	}
+}
+
+func TestQuarantine(t *testing.T) {
+	rows := []string{
+		snapshotRow("a", nil),
+		snapshotRow("b", map[string]string{"data_added": "lots"}),
+		snapshotRow("c", nil),
+		"d,1,2",
+		snapshotRow("e", nil),
+	}
+	dir, path := writeSnapshotsFile(t, rows...)
+	defer os.RemoveAll(dir)
+
+	problems, err := Diagnose(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	moved, err := Quarantine(problems)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved != 2 {
+		t.Errorf("got %v lines moved, want 2", moved)
+	}
+
+	if got, want := readFile(t, path+".rejected"), rows[1]+"\n"+rows[3]+"\n"; got != want {
+		t.Errorf("got rejected lines %q, want %q", got, want)
+	}
+
+	if problems, err := Diagnose(dir); err != nil || len(problems) > 0 {
+		t.Errorf("got problems %v (%v) after quarantine, want none", problems, err)
+	}
+	var ids []string
+	for _, summary := range csvReadSummaries(path) {
+		ids = append(ids, summary.SnapshotID)
+	}
+	if !reflect.DeepEqual(ids, []string{"a", "c", "e"}) {
+		t.Errorf("got snapshots %v after quarantine, want [a c e]", ids)
+	}
+}
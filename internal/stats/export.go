@@ -0,0 +1,274 @@
+package stats
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"time"
+)
+
+const (
+	// ExportFormatJSON is an identifier for exporting a metric as json
+	ExportFormatJSON = "json"
+
+	// ExportFormatCSV is an identifier for exporting a metric as comma separated values
+	ExportFormatCSV = "csv"
+
+	// ExportFormatTSV is an identifier for exporting a metric as tab separated values
+	ExportFormatTSV = "tsv"
+)
+
+// ExportFormats contains the supported export formats
+var ExportFormats = []string{
+	ExportFormatJSON,
+	ExportFormatCSV,
+	ExportFormatTSV,
+}
+
+// exportColumns is the header of a metric exported as csv or tsv, every row
+// carries the metadata of the metric so that rows of several exports can be
+// concatenated
+var exportColumns = []string{
+	"metric",
+	"group",
+	"repo",
+	"domain",
+	"aggregator",
+	"unit",
+	"date",
+	"label",
+	"value",
+	"formatted",
+}
+
+// Export is a machine-readable export of the values of a Metric
+type Export struct {
+	Metric     Metric
+	Aggregator Aggregator
+}
+
+// ExportedMetric is a Metric as exported to json
+type ExportedMetric struct {
+	Metric     string            `json:"metric"`
+	Title      string            `json:"title"`
+	Group      string            `json:"group"`
+	Repo       string            `json:"repo"`
+	Domain     string            `json:"domain"`
+	Aggregator string            `json:"aggregator"`
+	Unit       string            `json:"unit"`
+	Formatter  string            `json:"formatter"`
+	Values     []ExportedValue   `json:"values"`
+	Series     []ExportedSeries  `json:"series,omitempty"`
+	Forecast   *ExportedForecast `json:"forecast,omitempty"`
+}
+
+// ExportedForecast is the Forecast of a ForecastMetric as exported to json,
+// QuotaDate is nil if the quota is never reached
+type ExportedForecast struct {
+	Values        []ExportedValue `json:"values"`
+	PerDay        float64         `json:"per_day"`
+	Quota         float64         `json:"quota,omitempty"`
+	QuotaDate     *time.Time      `json:"quota_date,omitempty"`
+	QuotaExceeded bool            `json:"quota_exceeded,omitempty"`
+	Summary       string          `json:"summary"`
+}
+
+// ExportedSeries is a series of a StackedMetric as exported to json
+type ExportedSeries struct {
+	Name   string          `json:"name"`
+	Values []ExportedValue `json:"values"`
+}
+
+// ExportedValue is an aggregated value of a Metric as exported to json, the
+// Value of a gap is nil
+type ExportedValue struct {
+	Date      time.Time `json:"date"`
+	Label     string    `json:"label"`
+	Value     *float64  `json:"value"`
+	Formatted string    `json:"formatted"`
+}
+
+// Write writes Export e to w in given format
+func (e *Export) Write(w io.Writer, format string) error {
+	switch format {
+	case ExportFormatJSON:
+		return e.WriteJSON(w)
+	case ExportFormatCSV:
+		return e.WriteCSV(w, ',')
+	case ExportFormatTSV:
+		return e.WriteCSV(w, '\t')
+	}
+	return errors.New("unknown export format " + format)
+}
+
+// WriteJSON writes Export e to w as indented json
+func (e *Export) WriteJSON(w io.Writer) error {
+	return writeExportedJSON(w, e.Build())
+}
+
+// WriteCSV writes Export e to w as a header and one row per value, fields are
+// separated by given comma
+func (e *Export) WriteCSV(w io.Writer, comma rune) error {
+	return writeExportedCSV(w, comma, []ExportedMetric{e.Build()})
+}
+
+// WriteExports writes several exports to w in given format, as a json list or
+// as csv with a single header
+func WriteExports(w io.Writer, format string, exports []*Export) error {
+	exported := []ExportedMetric{}
+	for _, e := range exports {
+		exported = append(exported, e.Build())
+	}
+
+	switch format {
+	case ExportFormatJSON:
+		return writeExportedJSON(w, exported)
+	case ExportFormatCSV:
+		return writeExportedCSV(w, ',', exported)
+	case ExportFormatTSV:
+		return writeExportedCSV(w, '\t', exported)
+	}
+	return errors.New("unknown export format " + format)
+}
+
+// writeExportedJSON writes v to w as indented json
+func writeExportedJSON(w io.Writer, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return errors.New("failed to encode metric. " + err.Error())
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// writeExportedCSV writes a header and the values of given metrics to w, the
+// values of a metric with series are written with a row per series and date
+func writeExportedCSV(w io.Writer, comma rune, metrics []ExportedMetric) error {
+	writer := csv.NewWriter(w)
+	writer.Comma = comma
+	writer.Write(exportColumns)
+	for _, exported := range metrics {
+		series := exported.Series
+		if len(series) == 0 {
+			series = []ExportedSeries{{Name: exported.Domain, Values: exported.Values}}
+		}
+		for _, s := range series {
+			for _, value := range s.Values {
+				writer.Write([]string{
+					exported.Metric,
+					exported.Group,
+					exported.Repo,
+					s.Name,
+					exported.Aggregator,
+					exported.Unit,
+					value.Date.Format(time.RFC3339),
+					value.Label,
+					formatExportedValue(value.Value),
+					value.Formatted,
+				})
+			}
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// Build returns the labels and aggregated values of the metric of Export e
+// together with its metadata
+func (e *Export) Build() ExportedMetric {
+	formatter := e.Metric.GetFormatter()
+	domain := e.Metric.GetMetadata("domain")
+	if domain == AllDomains {
+		domain = ""
+	}
+	group := e.Metric.GetMetadata("group")
+	if group == AllGroups {
+		group = ""
+	}
+	repo := e.Metric.GetMetadata("repo")
+	if repo == AllRepos {
+		repo = ""
+	}
+
+	exported := ExportedMetric{
+		Metric:     e.Metric.GetMetadata("metric"),
+		Title:      e.Metric.GetTitle(),
+		Group:      group,
+		Repo:       repo,
+		Domain:     domain,
+		Aggregator: GetAggregatorName(e.Aggregator),
+		Unit:       formatter.Unit(),
+		Formatter:  GetFormatterName(formatter),
+	}
+
+	exported.Values = e.buildValues(e.Metric.GetValues(e.Aggregator))
+	if stacked, ok := e.Metric.(StackedMetric); ok {
+		for _, name := range stacked.GetSeriesNames() {
+			exported.Series = append(exported.Series, ExportedSeries{
+				Name:   name,
+				Values: e.buildValues(stacked.GetSeriesValues(name, e.Aggregator)),
+			})
+		}
+	}
+
+	if forecast, ok := e.Metric.(ForecastMetric); ok {
+		exported.Forecast = e.buildForecast(forecast.GetForecast(e.Aggregator))
+	}
+
+	return exported
+}
+
+// buildForecast returns Forecast f as exported to json
+func (e *Export) buildForecast(f *Forecast) *ExportedForecast {
+	formatter := e.Metric.GetFormatter()
+	exported := &ExportedForecast{
+		Values:        []ExportedValue{},
+		PerDay:        f.PerDay,
+		Quota:         f.Quota,
+		QuotaExceeded: f.QuotaExceeded,
+		Summary:       f.Summary(formatter),
+	}
+	for i := range f.Values {
+		exported.Values = append(exported.Values, ExportedValue{
+			Date:      f.Labels[i],
+			Label:     f.Labels[i].Format(e.Metric.GetDateLayout()),
+			Value:     &f.Values[i],
+			Formatted: formatter.Format(f.Values[i]),
+		})
+	}
+	if !f.QuotaDate.IsZero() {
+		exported.QuotaDate = &f.QuotaDate
+	}
+	return exported
+}
+
+// buildValues returns given values with the labels of the metric of Export e
+func (e *Export) buildValues(values []float64) []ExportedValue {
+	labels := e.Metric.GetLabels()
+	formatter := e.Metric.GetFormatter()
+
+	exported := []ExportedValue{}
+	for i, value := range values {
+		v := ExportedValue{
+			Date:  labels[i],
+			Label: labels[i].Format(e.Metric.GetDateLayout()),
+		}
+		if !math.IsNaN(value) {
+			v.Value = &values[i]
+			v.Formatted = formatter.Format(value)
+		}
+		exported = append(exported, v)
+	}
+	return exported
+}
+
+// formatExportedValue formats value for csv, a gap is an empty field
+func formatExportedValue(value *float64) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*value, 'f', -1, 64)
+}
@@ -0,0 +1,178 @@
+package stats
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// ForecastMetric is a Metric whose values are projected forward by fitting a
+// trend over them, and that can estimate when a quota is reached
+type ForecastMetric interface {
+	Metric
+	SetForecast(horizon int, quota float64)
+	GetForecast(a Aggregator) *Forecast
+}
+
+// Forecast is a projection of the values of a metric, a straight line fitted
+// over them with least squares that starts at the last value
+type Forecast struct {
+	Labels []time.Time
+	Values []float64
+
+	// PerDay is the growth of the values per day
+	PerDay float64
+
+	// Quota is the value the projection is compared with, 0 if there is no quota
+	Quota float64
+
+	// QuotaDate is the estimated date the quota is reached, zero if it never is
+	QuotaDate time.Time
+
+	// QuotaExceeded is whether the last value is already over the quota
+	QuotaExceeded bool
+}
+
+// maxDuration is the longest duration, a quota further away than it is treated
+// as never reached
+const maxDuration = time.Duration(math.MaxInt64)
+
+// NewForecast fits a trend over given labels and values and projects it horizon
+// time units past the last label. The projection starts at the last value, gaps
+// are left out of the fit
+func NewForecast(labels []time.Time, values []float64, timeUnit string, horizon int, quota float64) *Forecast {
+	f := &Forecast{Quota: quota}
+
+	var xs, ys []float64
+	for i, value := range values {
+		if math.IsNaN(value) {
+			continue
+		}
+		xs = append(xs, float64(labels[i].Unix()))
+		ys = append(ys, value)
+	}
+	if len(xs) == 0 {
+		return f
+	}
+
+	slope := fitSlope(xs, ys)
+	f.PerDay = slope * 24 * 60 * 60
+
+	lastX, lastY := xs[len(xs)-1], ys[len(ys)-1]
+	last := time.Unix(int64(lastX), 0).In(labels[0].Location())
+	f.Labels = append(f.Labels, last)
+	f.Values = append(f.Values, lastY)
+	for i := 1; i <= horizon; i++ {
+		date := addTimeUnits(last, timeUnit, i)
+		f.Labels = append(f.Labels, date)
+		f.Values = append(f.Values, lastY+slope*(float64(date.Unix())-lastX))
+	}
+
+	if quota > 0 {
+		if lastY >= quota {
+			f.QuotaExceeded = true
+		} else if seconds := (quota - lastY) / slope; slope > 0 && seconds < maxDuration.Seconds() {
+			f.QuotaDate = last.Add(time.Duration(seconds * float64(time.Second)))
+		}
+	}
+
+	return f
+}
+
+// fitSlope returns the slope of the straight line fitted over given points with
+// least squares, 0 if there are fewer than two points
+func fitSlope(xs []float64, ys []float64) float64 {
+	if len(xs) < 2 {
+		return 0
+	}
+
+	var meanX, meanY float64
+	for i := range xs {
+		meanX += xs[i]
+		meanY += ys[i]
+	}
+	meanX /= float64(len(xs))
+	meanY /= float64(len(ys))
+
+	var covariance, variance float64
+	for i := range xs {
+		covariance += (xs[i] - meanX) * (ys[i] - meanY)
+		variance += (xs[i] - meanX) * (xs[i] - meanX)
+	}
+	if variance == 0 {
+		return 0
+	}
+	return covariance / variance
+}
+
+// Summary describes Forecast f in a sentence, values are formatted with formatter
+func (f *Forecast) Summary(formatter Formatter) string {
+	if len(f.Values) == 0 {
+		return "not enough data to make a forecast"
+	}
+
+	growth := fmt.Sprintf("growing %s per day", formatter.Format(f.PerDay))
+	if f.PerDay <= 0 {
+		growth = fmt.Sprintf("shrinking %s per day", formatter.Format(-f.PerDay))
+	}
+
+	if f.Quota <= 0 {
+		last := len(f.Values) - 1
+		return fmt.Sprintf(
+			"%s, projected to %s on %s",
+			growth,
+			formatter.Format(f.Values[last]),
+			f.Labels[last].Format("2006-01-02"),
+		)
+	}
+
+	quota := formatter.Format(f.Quota)
+	if f.QuotaExceeded {
+		return fmt.Sprintf("%s, the quota of %s is already exceeded", growth, quota)
+	}
+	if f.QuotaDate.IsZero() {
+		return fmt.Sprintf("%s, the quota of %s will not be reached", growth, quota)
+	}
+	days := int(math.Ceil(time.Until(f.QuotaDate).Hours() / 24))
+	return fmt.Sprintf(
+		"%s, the quota of %s is estimated to be reached on %s, in %v days",
+		growth,
+		quota,
+		f.QuotaDate.Format("2006-01-02"),
+		days,
+	)
+}
+
+// forecastData provides the forecast of a ForecastMetric from the values of
+// its metricData
+type forecastData struct {
+	metricData
+	timeUnit string
+	horizon  int
+	quota    float64
+}
+
+// SetForecast sets how many time units forecastData m is projected, and the quota
+// to estimate the date of. A horizon of 0 projects as far as there is history
+func (m *forecastData) SetForecast(horizon int, quota float64) {
+	m.horizon = horizon
+	m.quota = quota
+}
+
+// GetForecast returns the forecast of the values of forecastData m aggregated
+// with aggregator a, dates without values of their own are left out, as values
+// carried by gap filling would bias the trend
+func (m *forecastData) GetForecast(a Aggregator) *Forecast {
+	horizon := m.horizon
+	if horizon <= 0 {
+		horizon = len(m.Dates) - 1
+	}
+
+	values := m.GetValues(a)
+	for i, data := range m.Data {
+		if len(data) == 0 {
+			values[i] = math.NaN()
+		}
+	}
+	return NewForecast(m.GetLabels(), values, m.timeUnit, horizon, m.quota)
+}
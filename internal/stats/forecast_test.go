@@ -0,0 +1,44 @@
+package stats
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestNewForecastQuotaTooFarAwayIsNotReached(t *testing.T) {
+	day := time.Date(2020, 3, 10, 0, 0, 0, 0, time.Local)
+	labels := []time.Time{day, day.AddDate(0, 0, 1), day.AddDate(0, 0, 2)}
+	values := []float64{1000000, 2000000, 3000000}
+
+	f := NewForecast(labels, values, TimeUnitDays, 2, 500*1024*1024*1024*1024)
+	if !f.QuotaDate.IsZero() {
+		t.Errorf("got quota date %v, want none", f.QuotaDate)
+	}
+
+	f = NewForecast(labels, values, TimeUnitDays, 2, 5000000)
+	if want := day.AddDate(0, 0, 4); !f.QuotaDate.Equal(want) {
+		t.Errorf("got quota date %v, want %v", f.QuotaDate, want)
+	}
+}
+
+func TestForecastLeavesOutCarriedValues(t *testing.T) {
+	day := time.Date(2020, 3, 10, 0, 0, 0, 0, time.Local)
+
+	db := NewDB()
+	insertDomainRawData(db, "a", 100, day.Add(time.Hour))
+	insertDomainRawData(db, "a", 200, day.AddDate(0, 0, 1).Add(time.Hour))
+
+	m, err := NewMetric("domain-disk-space-forecast", "repo", "demo", "a", TimeUnitDays, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.SetGapFill(GapFillCarry)
+	m.SetDateRange(day, day.AddDate(0, 0, 5))
+	m.FetchDataFromDB(db, TimeUnitDays, 0)
+
+	f := m.(ForecastMetric).GetForecast(&Last{})
+	if math.Abs(f.PerDay-100) > 1e-6 {
+		t.Errorf("got growth of %v per day, want 100", f.PerDay)
+	}
+}
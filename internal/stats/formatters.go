@@ -1,13 +1,41 @@
 package stats
 
 import (
+	"errors"
 	"fmt"
 	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
 )
 
-// Formatter is a type that implements a Format method for a float64 to a string
+// Formatters is a map of availible formatters
+var Formatters = map[string]Formatter{
+	"bytes":      &BytesFormatter{},
+	"amount":     &AmountFormatter{},
+	"time":       &TimeFormatter{},
+	"percent":    &PercentFormatter{},
+	"ratio":      &RatioFormatter{},
+	"throughput": &ThroughputFormatter{},
+	"currency":   &CurrencyFormatter{},
+}
+
+// Formatter is a type that implements a Format method for a float64 to a string,
+// and a Unit method returning the unit of the unformatted value
 type Formatter interface {
 	Format(value float64) string
+	Unit() string
+}
+
+// GetFormatterName returns the name formatter f is registered with in Formatters
+func GetFormatterName(f Formatter) string {
+	for name, formatter := range Formatters {
+		if reflect.TypeOf(formatter) == reflect.TypeOf(f) {
+			return name
+		}
+	}
+	return ""
 }
 
 // BytesFormatter formats a float64 of bytes to a  a nicely formatted
@@ -17,6 +45,9 @@ type BytesFormatter struct{}
 // Format formats provided float64 value to a string
 // source: https://yourbasic.org/golang/formatting-byte-size-to-human-readable-format/
 func (f *BytesFormatter) Format(value float64) string {
+	if value < 0 {
+		return "-" + f.Format(-value)
+	}
 	bytes := value
 	const unit = 1024
 	if bytes < unit {
@@ -34,12 +65,57 @@ func (f *BytesFormatter) Format(value float64) string {
 	)
 }
 
+// Unit returns the unit of values formatted by BytesFormatter f
+func (f *BytesFormatter) Unit() string {
+	return "bytes"
+}
+
+// byteUnits are the units understood by ParseBytes, KiB and friends are powers of
+// 1024 while KB and friends are powers of 1000
+var byteUnits = map[string]float64{
+	"":    1,
+	"b":   1,
+	"kb":  1e3,
+	"mb":  1e6,
+	"gb":  1e9,
+	"tb":  1e12,
+	"pb":  1e15,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
+	"pib": 1 << 50,
+}
+
+// bytesPattern matches a size such as 500GiB or 1.5 TB
+var bytesPattern = regexp.MustCompile(`^\s*([0-9]+(?:\.[0-9]+)?)\s*([a-zA-Z]*)\s*$`)
+
+// ParseBytes parses a size such as 500GiB or 1.5 TB to a number of bytes
+func ParseBytes(size string) (float64, error) {
+	match := bytesPattern.FindStringSubmatch(size)
+	if match == nil {
+		return 0, errors.New("invalid size " + size + ", expected a number and a unit such as 500GiB")
+	}
+	unit, ok := byteUnits[strings.ToLower(match[2])]
+	if !ok {
+		return 0, errors.New("unknown unit " + match[2] + " in size " + size)
+	}
+	value, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, errors.New("invalid size " + size + ". " + err.Error())
+	}
+	return value * unit, nil
+}
+
 // AmountFormatter formats an amount of "things" to a shorter representation as
 // a string, eg 1000 -> 1k, 1000000 -> 1m, etc.
 type AmountFormatter struct{}
 
 // Format formats provided float64 value to a string
 func (f *AmountFormatter) Format(value float64) string {
+	if value < 0 {
+		return "-" + f.Format(-value)
+	}
 	var exp string
 	if value >= 1000000000 {
 		value = math.Round(value*10) / 10000000000
@@ -54,12 +130,20 @@ func (f *AmountFormatter) Format(value float64) string {
 	return fmt.Sprintf("%.1f%s", value, exp)
 }
 
+// Unit returns the unit of values formatted by AmountFormatter f
+func (f *AmountFormatter) Unit() string {
+	return "count"
+}
+
 // TimeFormatter formats an amount of time to a shorter representation
 // such as 12 s, 31 min or 2.3 h
 type TimeFormatter struct{}
 
 // Format formats provided float64 value to a string
 func (f *TimeFormatter) Format(value float64) string {
+	if value < 0 {
+		return "-" + f.Format(-value)
+	}
 	var unit string
 	if value < 60 {
 		unit = "s"
@@ -72,3 +156,88 @@ func (f *TimeFormatter) Format(value float64) string {
 	}
 	return fmt.Sprintf("%.1f %s", value, unit)
 }
+
+// Unit returns the unit of values formatted by TimeFormatter f
+func (f *TimeFormatter) Unit() string {
+	return "seconds"
+}
+
+// PercentFormatter formats a percentage, eg. 42.0 %
+type PercentFormatter struct{}
+
+// Format formats provided float64 value to a string
+func (f *PercentFormatter) Format(value float64) string {
+	return fmt.Sprintf("%.1f %%", value)
+}
+
+// Unit returns the unit of values formatted by PercentFormatter f
+func (f *PercentFormatter) Unit() string {
+	return "percent"
+}
+
+// ThroughputFormatter formats bytes per second, eg. 12.3 MiB/s
+type ThroughputFormatter struct{}
+
+// Format formats provided float64 value to a string
+func (f *ThroughputFormatter) Format(value float64) string {
+	return (&BytesFormatter{}).Format(value) + "/s"
+}
+
+// Unit returns the unit of values formatted by ThroughputFormatter f
+func (f *ThroughputFormatter) Unit() string {
+	return "bytes per second"
+}
+
+// RatioFormatter formats a ratio, eg. 2.35x
+type RatioFormatter struct{}
+
+// Format formats provided float64 value to a string
+func (f *RatioFormatter) Format(value float64) string {
+	return fmt.Sprintf("%.2fx", value)
+}
+
+// Unit returns the unit of values formatted by RatioFormatter f
+func (f *RatioFormatter) Unit() string {
+	return "ratio"
+}
+
+// currencySymbols are the symbols written before amounts of some currencies,
+// amounts of other currencies are followed by the currency
+var currencySymbols = map[string]string{
+	"USD": "$",
+	"EUR": "€",
+	"GBP": "£",
+	"JPY": "¥",
+}
+
+// CurrencyFormatter formats an amount of money in Currency, eg. $12.34 or 12.34 SEK,
+// amounts below 1 are formatted with more decimals
+type CurrencyFormatter struct {
+	Currency string
+}
+
+// Format formats provided float64 value to a string
+func (f *CurrencyFormatter) Format(value float64) string {
+	if value < 0 {
+		return "-" + f.Format(-value)
+	}
+
+	amount := fmt.Sprintf("%.2f", value)
+	if value > 0 && value < 1 {
+		amount = fmt.Sprintf("%.4f", value)
+	}
+
+	if symbol, ok := currencySymbols[strings.ToUpper(f.Currency)]; ok {
+		return symbol + amount
+	}
+	return strings.TrimSpace(amount + " " + f.Currency)
+}
+
+// Unit returns the unit of values formatted by CurrencyFormatter f, the currency
+// if it is known
+func (f *CurrencyFormatter) Unit() string {
+	if f.Currency == "" {
+		return "currency"
+	}
+	return f.Currency
+}
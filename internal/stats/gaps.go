@@ -0,0 +1,167 @@
+package stats
+
+import (
+	"errors"
+	"math"
+	"strings"
+)
+
+const (
+	// MetricKindGauge is the kind of metrics of a size at a point in time, such as
+	// disk space, which is still there on dates without a backup
+	MetricKindGauge = "gauge"
+
+	// MetricKindCounter is the kind of metrics of things that happen during a
+	// backup, such as data added, which are 0 on dates without a backup
+	MetricKindCounter = "counter"
+
+	// GapFillCarry fills the dates without values of a gauge with the last known value
+	GapFillCarry = "carry"
+
+	// GapFillInterpolate fills the dates without values of a gauge with values on a
+	// straight line between the known values around them
+	GapFillInterpolate = "interpolate"
+
+	// GapFillGap leaves the dates without values of a gauge as gaps, a gap is NaN
+	GapFillGap = "gap"
+
+	// GapFillZero leaves the dates without values of a gauge to the aggregator, which
+	// for most aggregators means 0
+	GapFillZero = "zero"
+)
+
+// GapFills contains the supported gap fill strategies, the first is the default
+var GapFills = []string{
+	GapFillCarry,
+	GapFillInterpolate,
+	GapFillGap,
+	GapFillZero,
+}
+
+// ValidateGapFill returns an error if strategy is not a supported gap fill strategy
+func ValidateGapFill(strategy string) error {
+	for _, supported := range GapFills {
+		if strategy == supported {
+			return nil
+		}
+	}
+	return errors.New("unknown gap fill " + strategy + ", availible gap fills are " + strings.Join(GapFills, ", "))
+}
+
+// aggregateWithGaps aggregates data with aggregator a. For gauges, the dates
+// without values are first filled according to strategy, initial being the
+// values known from before the first date, if any
+func aggregateWithGaps(data [][]float64, a Aggregator, kind string, strategy string, initial []float64) []float64 {
+	filled := data
+	if kind == MetricKindGauge {
+		filled = fillGaps(data, strategy, initial)
+	}
+
+	var res []float64
+	for _, values := range filled {
+		res = a.Aggregate(res, values)
+	}
+
+	if kind == MetricKindGauge && strategy == GapFillGap {
+		for i := range filled {
+			if len(filled[i]) == 0 {
+				res[i] = math.NaN()
+			}
+		}
+	}
+	return res
+}
+
+// fillGaps returns a copy of data where the dates without values are filled
+// according to strategy. Dates before the first known value stay empty unless
+// initial values are given, and dates after the last known value are carried
+// forward when interpolating
+func fillGaps(data [][]float64, strategy string, initial []float64) [][]float64 {
+	if strategy == "" {
+		strategy = GapFills[0]
+	}
+	if strategy != GapFillCarry && strategy != GapFillInterpolate {
+		return data
+	}
+
+	filled := make([][]float64, len(data))
+	copy(filled, data)
+
+	previous := -1
+	var previousValue float64
+	hasPrevious := len(initial) > 0
+	if hasPrevious {
+		previousValue = initial[len(initial)-1]
+	}
+
+	for i := range data {
+		if len(data[i]) > 0 {
+			previous = i
+			previousValue = data[i][len(data[i])-1]
+			hasPrevious = true
+			continue
+		}
+		if !hasPrevious {
+			continue
+		}
+
+		value := previousValue
+		if strategy == GapFillInterpolate {
+			if next := nextWithValues(data, i); next >= 0 {
+				nextValue := data[next][0]
+				value = previousValue + (nextValue-previousValue)*float64(i-previous)/float64(next-previous)
+			}
+		}
+		filled[i] = []float64{value}
+	}
+
+	return filled
+}
+
+// nextWithValues returns the index of the first date after i that has values,
+// or -1 if there is none
+func nextWithValues(data [][]float64, i int) int {
+	for j := i + 1; j < len(data); j++ {
+		if len(data[j]) > 0 {
+			return j
+		}
+	}
+	return -1
+}
+
+// newGaps returns n values that are all gaps
+func newGaps(n int) []float64 {
+	values := make([]float64, n)
+	for i := range values {
+		values[i] = math.NaN()
+	}
+	return values
+}
+
+// sumWithGaps adds values to totals, gaps are left out so that a total is only
+// a gap if every value added to it was
+func sumWithGaps(totals []float64, values []float64) {
+	for i, value := range values {
+		if math.IsNaN(value) {
+			continue
+		}
+		if math.IsNaN(totals[i]) {
+			totals[i] = 0
+		}
+		totals[i] += value
+	}
+}
+
+// gapFormatter is a Formatter that formats gaps as "-" and other values
+// with the wrapped Formatter
+type gapFormatter struct {
+	Formatter
+}
+
+// Format formats value with the wrapped Formatter, or as "-" if it is a gap
+func (f *gapFormatter) Format(value float64) string {
+	if math.IsNaN(value) {
+		return "-"
+	}
+	return f.Formatter.Format(value)
+}
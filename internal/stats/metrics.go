@@ -4,31 +4,54 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"sort"
+	"strings"
 	"time"
 )
 
 const (
 	// AllDomains is a special string to mean all domains (".." cannot be a valid domain name)
 	AllDomains = ".."
+
+	// AllGroups is a special string to mean all groups (".." cannot be a valid group name)
+	AllGroups = ".."
+
+	// AllRepos is a special string to mean all repos (".." cannot be a valid repo name)
+	AllRepos = ".."
 )
 
 // Metrics is a map of availible metrics
 var Metrics = map[string]Metric{
 	"backup-time":                  &BackupTime{},
+	"domain-backup-time":           &DomainBackupTime{},
+	"repo-cost":                    &RepoCost{},
 	"repo-disk-space":              &RepoDiskSpace{},
+	"repo-disk-space-forecast":     &RepoDiskSpaceForecast{},
+	"repo-dedup-ratio":             &RepoDedupRatio{},
 	"domain-data-added":            &DomainDataAdded{},
+	"domain-dedup-ratio":           &DomainDedupRatio{},
 	"domain-disk-space":            &DomainDiskSpace{},
+	"domain-disk-space-forecast":   &DomainDiskSpaceForecast{},
 	"domain-disk-space-on-restore": &DomainDiskSpaceOnRestore{},
+	"domain-disk-space-stacked":    &DomainDiskSpaceStacked{},
+	"domain-disk-space-share":      &DomainDiskSpaceStacked{Share: true},
 	"domain-files-new-and-changed": &DomainFilesNewAndChanged{},
 	"domain-files-processed":       &DomainFilesProcessed{},
+	"domain-new-data-ratio":        &DomainNewDataRatio{},
+	"domain-throughput":            &DomainThroughput{},
+	"domain-file-churn":            &DomainFileChurn{},
+	"domain-dir-churn":             &DomainDirChurn{},
+	"domain-blobs-added":           &DomainBlobsAdded{},
 }
 
-// NewMetric returns a new metric
+// NewMetric returns a new instance of metric with given name
 func NewMetric(name string, repo string, group string, domain string, timeUnit string, aggregator string) (Metric, error) {
 	if _, ok := Metrics[name]; !ok {
 		return nil, errors.New("unknown metric " + name)
 	}
-	m := Metrics[name]
+	v := reflect.New(reflect.TypeOf(Metrics[name]).Elem())
+	v.Elem().Set(reflect.ValueOf(Metrics[name]).Elem())
+	m := v.Interface().(Metric)
 	m.Init(timeUnit)
 	m.SetTitle(name, repo, group, domain, aggregator)
 	m.SetMetadata(name, repo, group, domain, aggregator)
@@ -40,19 +63,33 @@ func NewMetric(name string, repo string, group string, domain string, timeUnit s
 type Metric interface {
 	SetTitle(name string, repo string, group string, domain string, aggregator string)
 	GetTitle() string
+	GetName() string
 	SupportsDomains() bool
 	SetMetadata(name string, repo string, group string, domain string, aggregator string)
 	GetMetadata(property string) string
 	GetValues(a Aggregator) []float64
+	GetRawValues() [][]float64
 	GetLabels() []time.Time
 	GetDateLayout() string
 	GetFormatter() Formatter
+	GetKind() string
+	SetGapFill(strategy string)
+	SetDateRange(from time.Time, to time.Time)
 
 	Init(timeUnit string)
 	GetDefaultAggregator() Aggregator
+	GetAggregators() []string
 	FetchDataFromDB(db *DB, timeUnit string, timeLength int)
 }
 
+// StackedMetric is a Metric made up of a series per domain, its values are
+// the sum of the series
+type StackedMetric interface {
+	Metric
+	GetSeriesNames() []string
+	GetSeriesValues(name string, a Aggregator) []float64
+}
+
 // metricData is type that provides for setter and getters for Metrics
 type metricData struct {
 	Title           string
@@ -63,6 +100,15 @@ type metricData struct {
 	Dates           []time.Time
 	DateLayout      string
 	Formatter       Formatter
+	aggregators     []string
+	kind            string
+	gapFill         string
+	initial         []float64
+	sources         []sourceKey
+	sourceData      map[sourceKey][][]float64
+	sourceInitial   map[sourceKey][]float64
+	from            time.Time
+	to              time.Time
 }
 
 // SetTitle sets the title of a metricData m from given input data
@@ -73,14 +119,30 @@ func (m *metricData) SetTitle(name string, repo string, group string, domain str
 	} else {
 		d = domain
 	}
-	m.Title = fmt.Sprintf(
-		"%s %s %s in group %s of repo %s",
+	m.Title = strings.TrimSpace(fmt.Sprintf(
+		"%s %s %s in %s of %s",
 		aggregator,
 		m.Name,
 		d,
-		group,
-		repo,
-	)
+		groupTitle(group),
+		repoTitle(repo),
+	))
+}
+
+// groupTitle returns how given group is named in titles
+func groupTitle(group string) string {
+	if group == AllGroups {
+		return "all groups"
+	}
+	return "group " + group
+}
+
+// repoTitle returns how given repo is named in titles
+func repoTitle(repo string) string {
+	if repo == AllRepos {
+		return "all repos"
+	}
+	return "repo " + repo
 }
 
 // GetTitle returns the title of metricData m
@@ -88,6 +150,11 @@ func (m *metricData) GetTitle() string {
 	return m.Title
 }
 
+// GetName returns the name of the quantity metricData m measures, as used in its title
+func (m *metricData) GetName() string {
+	return m.Name
+}
+
 // SupportsDomains returns whether metricData m supports specifying domain
 func (m *metricData) SupportsDomains() bool {
 	return m.supportsDomains
@@ -95,7 +162,7 @@ func (m *metricData) SupportsDomains() bool {
 
 // SetMetadata sets the metadata of metricData m
 func (m *metricData) SetMetadata(name string, repo string, group string, domain string, aggregator string) {
-	m.Meta = map[string]string{"repo": repo, "group": group, "domain": domain, "aggregator": aggregator}
+	m.Meta = map[string]string{"metric": name, "repo": repo, "group": group, "domain": domain, "aggregator": aggregator}
 }
 
 // GetMetadata returns value for requested metadata property from metricData m
@@ -110,10 +177,24 @@ func (m *metricData) GetMetadata(property string) string {
 func (m *metricData) AddDate(date time.Time) {
 	m.Dates = append(m.Dates, date)
 	m.Data = append(m.Data, []float64{})
+	for _, key := range m.sources {
+		m.sourceData[key] = append(m.sourceData[key], []float64{})
+	}
 }
 
 // AppendValues appends values from given field of provided object to given date for metricData m
 func (m *metricData) AppendValues(obj interface{}, field string, date int) {
+	value := fieldValue(obj, field)
+	m.Data[date] = append(m.Data[date], value)
+	if m.spansSources() {
+		key := keyOf(obj)
+		m.addSource(key)
+		m.sourceData[key][date] = append(m.sourceData[key][date], value)
+	}
+}
+
+// fieldValue returns the value of given field of provided object as a float64
+func fieldValue(obj interface{}, field string) float64 {
 	v := reflect.ValueOf(obj)
 	v = reflect.Indirect(v)
 	fv := v.FieldByName(field)
@@ -129,8 +210,7 @@ func (m *metricData) AppendValues(obj interface{}, field string, date int) {
 	default:
 		panic("cannot append value, unkown type")
 	}
-
-	m.Data[date] = append(m.Data[date], value)
+	return value
 }
 
 // AppendMultipleValues appends multiple fields to metricData m
@@ -140,13 +220,71 @@ func (m *metricData) AppendMultipleValues(obj interface{}, fields []string, date
 	}
 }
 
-// GetValues returns aggregated values from metricData m
+// SetInitialValues sets the values of given field of provided records, keyed by
+// group, repo and domain, as the last values known from before the first date of
+// metricData m
+func (m *metricData) SetInitialValues(records map[sourceKey]interface{}, field string) {
+	m.initial = nil
+	var last interface{}
+	for key, obj := range records {
+		if last == nil || recordDate(obj).After(recordDate(last)) {
+			last = obj
+		}
+		if m.spansSources() {
+			m.addSource(key)
+			m.sourceInitial[key] = []float64{fieldValue(obj, field)}
+		}
+	}
+	if last != nil {
+		m.initial = []float64{fieldValue(last, field)}
+	}
+}
+
+// spansSources returns whether metricData m is a gauge of several groups, repos
+// or domains, the values of a gauge are then kept per group, repo and domain and
+// summed, as the last value of one domain says nothing about the size of another
+func (m *metricData) spansSources() bool {
+	if m.kind != MetricKindGauge {
+		return false
+	}
+	if m.supportsDomains && m.Meta["domain"] == AllDomains {
+		return true
+	}
+	return m.Meta["group"] == AllGroups || m.Meta["repo"] == AllRepos
+}
+
+// addSource adds the group, repo and domain of key as a source of metricData m,
+// if it is not one already
+func (m *metricData) addSource(key sourceKey) {
+	if m.sourceData == nil {
+		m.sourceData = make(map[sourceKey][][]float64)
+		m.sourceInitial = make(map[sourceKey][]float64)
+	}
+	if _, ok := m.sourceData[key]; !ok {
+		m.sources = append(m.sources, key)
+		m.sourceData[key] = make([][]float64, len(m.Dates))
+	}
+}
+
+// GetValues returns aggregated values from metricData m, the dates without
+// values of a gauge are filled using the gap fill strategy of m. The values of
+// a gauge of several groups, repos or domains are the sum of the values of each
 func (m *metricData) GetValues(a Aggregator) []float64 {
-	var res []float64
-	for _, values := range m.Data {
-		res = a.Aggregate(res, values)
+	if len(m.sources) == 0 {
+		return aggregateWithGaps(m.Data, a, m.kind, m.gapFill, m.initial)
+	}
+
+	totals := newGaps(len(m.Dates))
+	for _, key := range m.sources {
+		sumWithGaps(totals, aggregateWithGaps(m.sourceData[key], a, m.kind, m.gapFill, m.sourceInitial[key]))
 	}
-	return res
+	return totals
+}
+
+// GetRawValues returns the values of metricData m before aggregation, one
+// slice of values per label
+func (m *metricData) GetRawValues() [][]float64 {
+	return m.Data
 }
 
 // GetLabels returns labels (time.Time) for values of metricData m
@@ -159,11 +297,45 @@ func (m *metricData) GetDateLayout() string {
 	return m.DateLayout
 }
 
+// GetAggregators returns the names of the aggregators that make sense for
+// metricData m, pN and moving-average-N means any percentile or moving average
+func (m *metricData) GetAggregators() []string {
+	return m.aggregators
+}
+
 // GetFormatter returns the formatter metricData m uses to format its values
 func (m *metricData) GetFormatter() Formatter {
 	return m.Formatter
 }
 
+// SetDateRange sets the dates metricData m has values for to the time units
+// from the one from is in until the one before to, instead of the last time units
+// until now. If only to is set, the time units before to are used
+func (m *metricData) SetDateRange(from time.Time, to time.Time) {
+	m.from = from
+	m.to = to
+}
+
+// newDateIterator returns an iterator over the dates metricData m should have
+// values for
+func (m *metricData) newDateIterator(db *DB, timeUnit string, timeLength int) DateIterator {
+	if m.to.IsZero() {
+		return NewDateIterator(db, timeUnit, timeLength)
+	}
+	return NewDateIteratorForRange(db, timeUnit, m.from, m.to, timeLength)
+}
+
+// GetKind returns whether metricData m is a gauge or a counter
+func (m *metricData) GetKind() string {
+	return m.kind
+}
+
+// SetGapFill sets the strategy used to fill the dates without values of
+// metricData m, if it is a gauge
+func (m *metricData) SetGapFill(strategy string) {
+	m.gapFill = strategy
+}
+
 // DomainDataAdded is a metric of the data added by snapshots of a domain over time
 type DomainDataAdded struct {
 	metricData
@@ -175,6 +347,13 @@ func (m *DomainDataAdded) Init(timeUnit string) {
 	m.DateLayout = getDateLayoutForTimeUnit(timeUnit)
 	m.Name = "data added to"
 	m.Formatter = &BytesFormatter{}
+	m.aggregators = flowAggregators
+	m.kind = MetricKindCounter
+}
+
+// GetAnomalyField returns the field of snapshots metric m is of
+func (m *DomainDataAdded) GetAnomalyField() string {
+	return "DataAdded"
 }
 
 // GetDefaultAggregator returns the default aggregator for metric m
@@ -183,13 +362,16 @@ func (m *DomainDataAdded) GetDefaultAggregator() Aggregator {
 }
 
 // FetchDataFromDB fetches appropriate data from DB and appends values
-// for given timeUnit and timeLength
+// for given timeUnit and timeLength. Backfilled snapshots are left out
 func (m *DomainDataAdded) FetchDataFromDB(db *DB, timeUnit string, timeLength int) {
-	iterator := NewDateIterator(db, timeUnit, timeLength)
+	iterator := m.newDateIterator(db, timeUnit, timeLength)
 	for date := iterator.Next(); date.Valid; date = iterator.Next() {
 		records := iterator.GetRecordsForDate("snapshot", m, date.Value)
 		m.AddDate(date.Value)
 		for obj := records.Next(); obj != nil; obj = records.Next() {
+			if backfilled(obj) {
+				continue
+			}
 			m.AppendValues(obj, "DataAdded", iterator.CurrentOffset)
 		}
 	}
@@ -207,6 +389,8 @@ func (m *DomainFilesNewAndChanged) Init(timeUnit string) {
 	m.DateLayout = getDateLayoutForTimeUnit(timeUnit)
 	m.Name = "new and changed files in"
 	m.Formatter = &AmountFormatter{}
+	m.aggregators = flowAggregators
+	m.kind = MetricKindCounter
 }
 
 // GetDefaultAggregator returns the default aggregator for metric m
@@ -215,13 +399,16 @@ func (m *DomainFilesNewAndChanged) GetDefaultAggregator() Aggregator {
 }
 
 // FetchDataFromDB fetches appropriate data from DB and appends values
-// for given timeUnit and timeLength
+// for given timeUnit and timeLength. Backfilled snapshots are left out
 func (m *DomainFilesNewAndChanged) FetchDataFromDB(db *DB, timeUnit string, timeLength int) {
-	iterator := NewDateIterator(db, timeUnit, timeLength)
+	iterator := m.newDateIterator(db, timeUnit, timeLength)
 	for date := iterator.Next(); date.Valid; date = iterator.Next() {
 		records := iterator.GetRecordsForDate("snapshot", m, date.Value)
 		m.AddDate(date.Value)
 		for obj := records.Next(); obj != nil; obj = records.Next() {
+			if backfilled(obj) {
+				continue
+			}
 			m.AppendMultipleValues(obj, []string{"FilesNew", "FilesChanged"}, iterator.CurrentOffset)
 		}
 	}
@@ -239,6 +426,13 @@ func (m *DomainFilesProcessed) Init(timeUnit string) {
 	m.DateLayout = getDateLayoutForTimeUnit(timeUnit)
 	m.Name = "files processed when backing up"
 	m.Formatter = &AmountFormatter{}
+	m.aggregators = flowAggregators
+	m.kind = MetricKindCounter
+}
+
+// GetAnomalyField returns the field of snapshots metric m is of
+func (m *DomainFilesProcessed) GetAnomalyField() string {
+	return "TotalFilesProcessed"
 }
 
 // GetDefaultAggregator returns the default aggregator for metric m
@@ -249,7 +443,7 @@ func (m *DomainFilesProcessed) GetDefaultAggregator() Aggregator {
 // FetchDataFromDB fetches appropriate data from DB and appends values
 // for given timeUnit and timeLength
 func (m *DomainFilesProcessed) FetchDataFromDB(db *DB, timeUnit string, timeLength int) {
-	iterator := NewDateIterator(db, timeUnit, timeLength)
+	iterator := m.newDateIterator(db, timeUnit, timeLength)
 	for date := iterator.Next(); date.Valid; date = iterator.Next() {
 		records := iterator.GetRecordsForDate("snapshot", m, date.Value)
 		m.AddDate(date.Value)
@@ -270,6 +464,8 @@ func (m *BackupTime) Init(timeUnit string) {
 	m.DateLayout = getDateLayoutForTimeUnit(timeUnit)
 	m.Name = "backup time of"
 	m.Formatter = &TimeFormatter{}
+	m.aggregators = durationAggregators
+	m.kind = MetricKindCounter
 }
 
 // GetDefaultAggregator returns the default aggregator for metric m
@@ -280,7 +476,7 @@ func (m *BackupTime) GetDefaultAggregator() Aggregator {
 // FetchDataFromDB fetches appropriate data from DB and appends values
 // for given timeUnit and timeLength
 func (m *BackupTime) FetchDataFromDB(db *DB, timeUnit string, timeLength int) {
-	iterator := NewDateIterator(db, timeUnit, timeLength)
+	iterator := m.newDateIterator(db, timeUnit, timeLength)
 	for date := iterator.Next(); date.Valid; date = iterator.Next() {
 		records := iterator.GetRecordsForDate("repo_backup_times", m, date.Value)
 		m.AddDate(date.Value)
@@ -290,6 +486,47 @@ func (m *BackupTime) FetchDataFromDB(db *DB, timeUnit string, timeLength int) {
 	}
 }
 
+// DomainBackupTime is a metric of the time it took to take a snapshot of a domain
+type DomainBackupTime struct {
+	metricData
+}
+
+// Init initializes the metric
+func (m *DomainBackupTime) Init(timeUnit string) {
+	m.supportsDomains = true
+	m.DateLayout = getDateLayoutForTimeUnit(timeUnit)
+	m.Name = "backup time of"
+	m.Formatter = &TimeFormatter{}
+	m.aggregators = durationAggregators
+	m.kind = MetricKindCounter
+}
+
+// GetAnomalyField returns the field of snapshots metric m is of
+func (m *DomainBackupTime) GetAnomalyField() string {
+	return "TotalDuration"
+}
+
+// GetDefaultAggregator returns the default aggregator for metric m
+func (m *DomainBackupTime) GetDefaultAggregator() Aggregator {
+	return &Average{}
+}
+
+// FetchDataFromDB fetches appropriate data from DB and appends values
+// for given timeUnit and timeLength. Backfilled snapshots are left out
+func (m *DomainBackupTime) FetchDataFromDB(db *DB, timeUnit string, timeLength int) {
+	iterator := m.newDateIterator(db, timeUnit, timeLength)
+	for date := iterator.Next(); date.Valid; date = iterator.Next() {
+		records := iterator.GetRecordsForDate("snapshot", m, date.Value)
+		m.AddDate(date.Value)
+		for obj := records.Next(); obj != nil; obj = records.Next() {
+			if backfilled(obj) {
+				continue
+			}
+			m.AppendValues(obj, "TotalDuration", iterator.CurrentOffset)
+		}
+	}
+}
+
 // RepoDiskSpace is a metric of how much space a repo takes on disk
 type RepoDiskSpace struct {
 	metricData
@@ -301,17 +538,20 @@ func (m *RepoDiskSpace) Init(timeUnit string) {
 	m.DateLayout = getDateLayoutForTimeUnit(timeUnit)
 	m.Name = "disk space occupied by"
 	m.Formatter = &BytesFormatter{}
+	m.aggregators = gaugeAggregators
+	m.kind = MetricKindGauge
 }
 
 // GetDefaultAggregator returns the default aggregator for metric m
 func (m *RepoDiskSpace) GetDefaultAggregator() Aggregator {
-	return &Average{}
+	return &Last{}
 }
 
 // FetchDataFromDB fetches appropriate data from DB and appends values
 // for given timeUnit and timeLength
 func (m *RepoDiskSpace) FetchDataFromDB(db *DB, timeUnit string, timeLength int) {
-	iterator := NewDateIterator(db, timeUnit, timeLength)
+	iterator := m.newDateIterator(db, timeUnit, timeLength)
+	m.SetInitialValues(iterator.GetLastRecordsPerSourceBefore("repo_raw_data", m), "TotalSize")
 	for date := iterator.Next(); date.Valid; date = iterator.Next() {
 		records := iterator.GetRecordsForDate("repo_raw_data", m, date.Value)
 		m.AddDate(date.Value)
@@ -332,17 +572,92 @@ func (m *DomainDiskSpace) Init(timeUnit string) {
 	m.DateLayout = getDateLayoutForTimeUnit(timeUnit)
 	m.Name = "disk space occupied by"
 	m.Formatter = &BytesFormatter{}
+	m.aggregators = gaugeAggregators
+	m.kind = MetricKindGauge
 }
 
 // GetDefaultAggregator returns the default aggregator for metric m
 func (m *DomainDiskSpace) GetDefaultAggregator() Aggregator {
-	return &Average{}
+	return &Last{}
 }
 
 // FetchDataFromDB fetches appropriate data from DB and appends values
 // for given timeUnit and timeLength
 func (m *DomainDiskSpace) FetchDataFromDB(db *DB, timeUnit string, timeLength int) {
-	iterator := NewDateIterator(db, timeUnit, timeLength)
+	iterator := m.newDateIterator(db, timeUnit, timeLength)
+	m.SetInitialValues(iterator.GetLastRecordsPerSourceBefore("domain_raw_data", m), "TotalSize")
+	for date := iterator.Next(); date.Valid; date = iterator.Next() {
+		records := iterator.GetRecordsForDate("domain_raw_data", m, date.Value)
+		m.AddDate(date.Value)
+		for obj := records.Next(); obj != nil; obj = records.Next() {
+			m.AppendValues(obj, "TotalSize", iterator.CurrentOffset)
+		}
+	}
+}
+
+// RepoDiskSpaceForecast is a metric of how much space a repo takes on disk,
+// projected forward
+type RepoDiskSpaceForecast struct {
+	forecastData
+}
+
+// Init initializes the metric
+func (m *RepoDiskSpaceForecast) Init(timeUnit string) {
+	m.supportsDomains = false
+	m.DateLayout = getDateLayoutForTimeUnit(timeUnit)
+	m.Name = "forecast of disk space occupied by"
+	m.Formatter = &BytesFormatter{}
+	m.aggregators = forecastAggregators
+	m.kind = MetricKindGauge
+	m.timeUnit = timeUnit
+}
+
+// GetDefaultAggregator returns the default aggregator for metric m
+func (m *RepoDiskSpaceForecast) GetDefaultAggregator() Aggregator {
+	return &Last{}
+}
+
+// FetchDataFromDB fetches appropriate data from DB and appends values
+// for given timeUnit and timeLength
+func (m *RepoDiskSpaceForecast) FetchDataFromDB(db *DB, timeUnit string, timeLength int) {
+	iterator := m.newDateIterator(db, timeUnit, timeLength)
+	m.SetInitialValues(iterator.GetLastRecordsPerSourceBefore("repo_raw_data", m), "TotalSize")
+	for date := iterator.Next(); date.Valid; date = iterator.Next() {
+		records := iterator.GetRecordsForDate("repo_raw_data", m, date.Value)
+		m.AddDate(date.Value)
+		for obj := records.Next(); obj != nil; obj = records.Next() {
+			m.AppendValues(obj, "TotalSize", iterator.CurrentOffset)
+		}
+	}
+}
+
+// DomainDiskSpaceForecast is a metric of how much space all the backups of a
+// domain takes on disk, projected forward
+type DomainDiskSpaceForecast struct {
+	forecastData
+}
+
+// Init initializes the metric
+func (m *DomainDiskSpaceForecast) Init(timeUnit string) {
+	m.supportsDomains = true
+	m.DateLayout = getDateLayoutForTimeUnit(timeUnit)
+	m.Name = "forecast of disk space occupied by"
+	m.Formatter = &BytesFormatter{}
+	m.aggregators = forecastAggregators
+	m.kind = MetricKindGauge
+	m.timeUnit = timeUnit
+}
+
+// GetDefaultAggregator returns the default aggregator for metric m
+func (m *DomainDiskSpaceForecast) GetDefaultAggregator() Aggregator {
+	return &Last{}
+}
+
+// FetchDataFromDB fetches appropriate data from DB and appends values
+// for given timeUnit and timeLength
+func (m *DomainDiskSpaceForecast) FetchDataFromDB(db *DB, timeUnit string, timeLength int) {
+	iterator := m.newDateIterator(db, timeUnit, timeLength)
+	m.SetInitialValues(iterator.GetLastRecordsPerSourceBefore("domain_raw_data", m), "TotalSize")
 	for date := iterator.Next(); date.Valid; date = iterator.Next() {
 		records := iterator.GetRecordsForDate("domain_raw_data", m, date.Value)
 		m.AddDate(date.Value)
@@ -352,6 +667,121 @@ func (m *DomainDiskSpace) FetchDataFromDB(db *DB, timeUnit string, timeLength in
 	}
 }
 
+// RepoCost is a metric of how much it costs to store a repo, priced with the
+// Pricing of the repo. The cost of a domain is its share of the cost of the repo,
+// apportioned by the disk space of the domains
+type RepoCost struct {
+	metricData
+	pricing    *Pricing
+	pricingErr error
+}
+
+// Init initializes the metric
+func (m *RepoCost) Init(timeUnit string) {
+	m.supportsDomains = true
+	m.DateLayout = getDateLayoutForTimeUnit(timeUnit)
+	m.Name = "cost of storing"
+	m.Formatter = &CurrencyFormatter{}
+	m.aggregators = costAggregators
+	m.kind = MetricKindCounter
+}
+
+// SetMetadata sets the metadata of metric m and loads the pricing of its repo
+func (m *RepoCost) SetMetadata(name string, repo string, group string, domain string, aggregator string) {
+	m.metricData.SetMetadata(name, repo, group, domain, aggregator)
+	if group == AllGroups || repo == AllRepos {
+		m.pricing, m.pricingErr = nil, errors.New("the cost can only be computed for a single group and repo")
+		return
+	}
+	m.pricing, m.pricingErr = LoadPricing(group, repo)
+	if m.pricing != nil {
+		m.Formatter = &CurrencyFormatter{Currency: m.pricing.Currency}
+	}
+}
+
+// GetPricing returns the pricing of the repo of metric m, or an error if it
+// could not be loaded
+func (m *RepoCost) GetPricing() (*Pricing, error) {
+	return m.pricing, m.pricingErr
+}
+
+// GetDefaultAggregator returns the default aggregator for metric m
+func (m *RepoCost) GetDefaultAggregator() Aggregator {
+	return &Sum{}
+}
+
+// FetchDataFromDB fetches appropriate data from DB and appends values
+// for given timeUnit and timeLength. The cost of a date is the disk space of
+// the repo integrated over the date, a size is used from the time it was
+// recorded until the next size was recorded
+func (m *RepoCost) FetchDataFromDB(db *DB, timeUnit string, timeLength int) {
+	iterator := m.newDateIterator(db, timeUnit, timeLength)
+	now := time.Now()
+
+	repoSize := -1.0
+	if obj := iterator.GetLastRecordBefore("repo_raw_data", m); obj != nil {
+		repoSize = fieldValue(obj, "TotalSize")
+	}
+	domainSizes := sizesPerSource(iterator.GetLastRecordsPerDomainBefore("domain_raw_data", m))
+
+	for date := iterator.Next(); date.Valid; date = iterator.Next() {
+		m.AddDate(date.Value)
+
+		records := mergeChronologically(
+			iterator.GetRepoRecordsForDate("repo_raw_data", m, date.Value),
+			iterator.GetRecordsForDateInAllDomains("domain_raw_data", m, date.Value),
+		)
+
+		from := date.Value
+		end := addTimeUnits(date.Value, timeUnit, 1)
+		if end.After(now) {
+			end = now
+		}
+
+		cost := 0.0
+		for _, obj := range records {
+			cost += m.costBetween(from, recordDate(obj), repoSize, domainSizes)
+			from = recordDate(obj)
+			switch record := obj.(type) {
+			case *RepoRawData:
+				repoSize = float64(record.TotalSize)
+			case *DomainRawData:
+				domainSizes[keyOf(record)] = float64(record.TotalSize)
+			}
+		}
+		cost += m.costBetween(from, end, repoSize, domainSizes)
+
+		m.Data[iterator.CurrentOffset] = append(m.Data[iterator.CurrentOffset], cost)
+	}
+}
+
+// costBetween returns the cost of storing repoSize bytes from from until to,
+// for a domain only its share of domainSizes is returned. A negative repoSize
+// means the size is not known yet
+func (m *RepoCost) costBetween(from time.Time, to time.Time, repoSize float64, domainSizes map[sourceKey]float64) float64 {
+	if m.pricing == nil || repoSize < 0 || !to.After(from) {
+		return 0
+	}
+
+	cost := m.pricing.MonthlyCost(repoSize) * to.Sub(from).Hours() / hoursPerMonth
+
+	domain := m.GetMetadata("domain")
+	if domain == AllDomains {
+		return cost
+	}
+	var total, share float64
+	for key, size := range domainSizes {
+		total += size
+		if key.Domain == domain {
+			share += size
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return cost * share / total
+}
+
 // DomainDiskSpaceOnRestore is a metric of how much disk space a domain would take on restore
 type DomainDiskSpaceOnRestore struct {
 	metricData
@@ -359,7 +789,7 @@ type DomainDiskSpaceOnRestore struct {
 
 // GetDefaultAggregator returns the default aggregator for metric m
 func (m *DomainDiskSpaceOnRestore) GetDefaultAggregator() Aggregator {
-	return &Average{}
+	return &Last{}
 }
 
 // Init initializes the metric
@@ -368,17 +798,532 @@ func (m *DomainDiskSpaceOnRestore) Init(timeUnit string) {
 	m.DateLayout = getDateLayoutForTimeUnit(timeUnit)
 	m.Name = "size of the restore of"
 	m.Formatter = &BytesFormatter{}
+	m.aggregators = gaugeAggregators
+	m.kind = MetricKindGauge
 }
 
 // FetchDataFromDB fetches appropriate data from DB and appends values
 // for given timeUnit and timeLength
 func (m *DomainDiskSpaceOnRestore) FetchDataFromDB(db *DB, timeUnit string, timeLength int) {
-	iterator := NewDateIterator(db, timeUnit, timeLength)
+	iterator := m.newDateIterator(db, timeUnit, timeLength)
+	m.SetInitialValues(iterator.GetLastRecordsPerSourceBefore("domain_restore_size", m), "TotalSize")
 	for date := iterator.Next(); date.Valid; date = iterator.Next() {
-		records := iterator.GetRecordsForDate("domain_raw_data", m, date.Value)
+		records := iterator.GetRecordsForDate("domain_restore_size", m, date.Value)
 		m.AddDate(date.Value)
 		for obj := records.Next(); obj != nil; obj = records.Next() {
 			m.AppendValues(obj, "TotalSize", iterator.CurrentOffset)
 		}
 	}
 }
+
+// DomainDedupRatio is a metric of how well the data of a domain deduplicates and
+// compresses, the size of a restore of the latest snapshot divided by the space
+// all the backups of the domain takes on disk
+type DomainDedupRatio struct {
+	metricData
+}
+
+// Init initializes the metric
+func (m *DomainDedupRatio) Init(timeUnit string) {
+	m.supportsDomains = true
+	m.DateLayout = getDateLayoutForTimeUnit(timeUnit)
+	m.Name = "deduplication ratio of"
+	m.Formatter = &RatioFormatter{}
+	m.aggregators = ratioAggregators
+	m.kind = MetricKindGauge
+}
+
+// GetDefaultAggregator returns the default aggregator for metric m
+func (m *DomainDedupRatio) GetDefaultAggregator() Aggregator {
+	return &Last{}
+}
+
+// FetchDataFromDB fetches appropriate data from DB and appends values
+// for given timeUnit and timeLength. The ratio of all domains is the total size
+// of their restores divided by the total space they take on disk
+func (m *DomainDedupRatio) FetchDataFromDB(db *DB, timeUnit string, timeLength int) {
+	iterator := m.newDateIterator(db, timeUnit, timeLength)
+	domain := m.GetMetadata("domain")
+
+	restoreSizes := sizesPerSource(iterator.GetLastRecordsPerDomainBefore("domain_restore_size", m))
+	diskSizes := sizesPerSource(iterator.GetLastRecordsPerDomainBefore("domain_raw_data", m))
+	m.initial = nil
+	if ratio, ok := domainSizeRatio(restoreSizes, diskSizes, domain); ok {
+		m.initial = []float64{ratio}
+	}
+
+	for date := iterator.Next(); date.Valid; date = iterator.Next() {
+		m.AddDate(date.Value)
+		records := mergeChronologically(
+			iterator.GetRecordsForDate("domain_restore_size", m, date.Value),
+			iterator.GetRecordsForDate("domain_raw_data", m, date.Value),
+		)
+		if len(records) == 0 {
+			continue
+		}
+		for _, obj := range records {
+			switch record := obj.(type) {
+			case *DomainRestoreSize:
+				restoreSizes[keyOf(record)] = float64(record.TotalSize)
+			case *DomainRawData:
+				diskSizes[keyOf(record)] = float64(record.TotalSize)
+			}
+		}
+		if ratio, ok := domainSizeRatio(restoreSizes, diskSizes, domain); ok {
+			m.Data[iterator.CurrentOffset] = append(m.Data[iterator.CurrentOffset], ratio)
+		}
+	}
+}
+
+// RepoDedupRatio is a metric of how well the data in a repo deduplicates and
+// compresses, the total size of a restore of the latest snapshot of every domain
+// divided by the space the repo takes on disk. Data shared between domains is
+// only stored once, so the ratio of a repo can be higher than that of its domains
+type RepoDedupRatio struct {
+	metricData
+}
+
+// Init initializes the metric
+func (m *RepoDedupRatio) Init(timeUnit string) {
+	m.supportsDomains = false
+	m.DateLayout = getDateLayoutForTimeUnit(timeUnit)
+	m.Name = "deduplication ratio of"
+	m.Formatter = &RatioFormatter{}
+	m.aggregators = ratioAggregators
+	m.kind = MetricKindGauge
+}
+
+// GetDefaultAggregator returns the default aggregator for metric m
+func (m *RepoDedupRatio) GetDefaultAggregator() Aggregator {
+	return &Last{}
+}
+
+// FetchDataFromDB fetches appropriate data from DB and appends values
+// for given timeUnit and timeLength
+func (m *RepoDedupRatio) FetchDataFromDB(db *DB, timeUnit string, timeLength int) {
+	iterator := m.newDateIterator(db, timeUnit, timeLength)
+
+	restoreSizes := sizesPerSource(iterator.GetLastRecordsPerDomainBefore("domain_restore_size", m))
+	repoSizes := sizesPerSource(iterator.GetLastRecordsPerSourceBefore("repo_raw_data", m))
+	m.initial = nil
+	if ratio, ok := repoSizeRatio(restoreSizes, repoSizes); ok {
+		m.initial = []float64{ratio}
+	}
+
+	for date := iterator.Next(); date.Valid; date = iterator.Next() {
+		m.AddDate(date.Value)
+		records := mergeChronologically(
+			iterator.GetRecordsForDateInAllDomains("domain_restore_size", m, date.Value),
+			iterator.GetRecordsForDate("repo_raw_data", m, date.Value),
+		)
+		if len(records) == 0 {
+			continue
+		}
+		for _, obj := range records {
+			switch record := obj.(type) {
+			case *DomainRestoreSize:
+				restoreSizes[keyOf(record)] = float64(record.TotalSize)
+			case *RepoRawData:
+				repoSizes[keyOf(record)] = float64(record.TotalSize)
+			}
+		}
+		if ratio, ok := repoSizeRatio(restoreSizes, repoSizes); ok {
+			m.Data[iterator.CurrentOffset] = append(m.Data[iterator.CurrentOffset], ratio)
+		}
+	}
+}
+
+// DomainNewDataRatio is a metric of how much of the data processed by the
+// snapshots of a domain was new and added to the repo, in percent
+type DomainNewDataRatio struct {
+	metricData
+}
+
+// Init initializes the metric
+func (m *DomainNewDataRatio) Init(timeUnit string) {
+	m.supportsDomains = true
+	m.DateLayout = getDateLayoutForTimeUnit(timeUnit)
+	m.Name = "share of new data in snapshots of"
+	m.Formatter = &PercentFormatter{}
+	m.aggregators = ratioAggregators
+	m.kind = MetricKindCounter
+}
+
+// GetDefaultAggregator returns the default aggregator for metric m
+func (m *DomainNewDataRatio) GetDefaultAggregator() Aggregator {
+	return &Average{}
+}
+
+// FetchDataFromDB fetches appropriate data from DB and appends values
+// for given timeUnit and timeLength. Backfilled snapshots and snapshots without
+// any bytes processed are left out
+func (m *DomainNewDataRatio) FetchDataFromDB(db *DB, timeUnit string, timeLength int) {
+	iterator := m.newDateIterator(db, timeUnit, timeLength)
+	for date := iterator.Next(); date.Valid; date = iterator.Next() {
+		records := iterator.GetRecordsForDate("snapshot", m, date.Value)
+		m.AddDate(date.Value)
+		for obj := records.Next(); obj != nil; obj = records.Next() {
+			if backfilled(obj) {
+				continue
+			}
+			snapshot := obj.(*SnapshotSummary)
+			if snapshot.TotalBytesProcessed <= 0 {
+				continue
+			}
+			ratio := float64(snapshot.DataAdded) / float64(snapshot.TotalBytesProcessed) * 100
+			m.Data[iterator.CurrentOffset] = append(m.Data[iterator.CurrentOffset], ratio)
+		}
+	}
+}
+
+// DomainThroughput is a metric of how many bytes per second were processed while
+// taking a snapshot of a domain
+type DomainThroughput struct {
+	metricData
+}
+
+// Init initializes the metric
+func (m *DomainThroughput) Init(timeUnit string) {
+	m.supportsDomains = true
+	m.DateLayout = getDateLayoutForTimeUnit(timeUnit)
+	m.Name = "backup throughput of"
+	m.Formatter = &ThroughputFormatter{}
+	m.aggregators = ratioAggregators
+	m.kind = MetricKindCounter
+}
+
+// GetDefaultAggregator returns the default aggregator for metric m
+func (m *DomainThroughput) GetDefaultAggregator() Aggregator {
+	return &Average{}
+}
+
+// FetchDataFromDB fetches appropriate data from DB and appends values
+// for given timeUnit and timeLength. Snapshots without a duration, such as
+// backfilled snapshots, are left out
+func (m *DomainThroughput) FetchDataFromDB(db *DB, timeUnit string, timeLength int) {
+	iterator := m.newDateIterator(db, timeUnit, timeLength)
+	for date := iterator.Next(); date.Valid; date = iterator.Next() {
+		records := iterator.GetRecordsForDate("snapshot", m, date.Value)
+		m.AddDate(date.Value)
+		for obj := records.Next(); obj != nil; obj = records.Next() {
+			snapshot := obj.(*SnapshotSummary)
+			if snapshot.TotalDuration <= 0 {
+				continue
+			}
+			throughput := float64(snapshot.TotalBytesProcessed) / snapshot.TotalDuration
+			m.Data[iterator.CurrentOffset] = append(m.Data[iterator.CurrentOffset], throughput)
+		}
+	}
+}
+
+// DomainFileChurn is a metric of how many of the files processed by the snapshots
+// of a domain were new or changed, in percent
+type DomainFileChurn struct {
+	metricData
+}
+
+// Init initializes the metric
+func (m *DomainFileChurn) Init(timeUnit string) {
+	m.supportsDomains = true
+	m.DateLayout = getDateLayoutForTimeUnit(timeUnit)
+	m.Name = "share of new and changed files in snapshots of"
+	m.Formatter = &PercentFormatter{}
+	m.aggregators = ratioAggregators
+	m.kind = MetricKindCounter
+}
+
+// GetDefaultAggregator returns the default aggregator for metric m
+func (m *DomainFileChurn) GetDefaultAggregator() Aggregator {
+	return &Average{}
+}
+
+// FetchDataFromDB fetches appropriate data from DB and appends values
+// for given timeUnit and timeLength. Backfilled snapshots and snapshots without
+// any files processed are left out
+func (m *DomainFileChurn) FetchDataFromDB(db *DB, timeUnit string, timeLength int) {
+	iterator := m.newDateIterator(db, timeUnit, timeLength)
+	for date := iterator.Next(); date.Valid; date = iterator.Next() {
+		records := iterator.GetRecordsForDate("snapshot", m, date.Value)
+		m.AddDate(date.Value)
+		for obj := records.Next(); obj != nil; obj = records.Next() {
+			if backfilled(obj) {
+				continue
+			}
+			snapshot := obj.(*SnapshotSummary)
+			if snapshot.TotalFilesProcessed <= 0 {
+				continue
+			}
+			churn := float64(snapshot.FilesNew+snapshot.FilesChanged) / float64(snapshot.TotalFilesProcessed) * 100
+			m.Data[iterator.CurrentOffset] = append(m.Data[iterator.CurrentOffset], churn)
+		}
+	}
+}
+
+// DomainDirChurn is a metric of how many of the directories in the snapshots of
+// a domain were new or changed, in percent
+type DomainDirChurn struct {
+	metricData
+}
+
+// Init initializes the metric
+func (m *DomainDirChurn) Init(timeUnit string) {
+	m.supportsDomains = true
+	m.DateLayout = getDateLayoutForTimeUnit(timeUnit)
+	m.Name = "share of new and changed directories in snapshots of"
+	m.Formatter = &PercentFormatter{}
+	m.aggregators = ratioAggregators
+	m.kind = MetricKindCounter
+}
+
+// GetDefaultAggregator returns the default aggregator for metric m
+func (m *DomainDirChurn) GetDefaultAggregator() Aggregator {
+	return &Average{}
+}
+
+// FetchDataFromDB fetches appropriate data from DB and appends values
+// for given timeUnit and timeLength. Snapshots without any directories are
+// left out
+func (m *DomainDirChurn) FetchDataFromDB(db *DB, timeUnit string, timeLength int) {
+	iterator := m.newDateIterator(db, timeUnit, timeLength)
+	for date := iterator.Next(); date.Valid; date = iterator.Next() {
+		records := iterator.GetRecordsForDate("snapshot", m, date.Value)
+		m.AddDate(date.Value)
+		for obj := records.Next(); obj != nil; obj = records.Next() {
+			snapshot := obj.(*SnapshotSummary)
+			changed := snapshot.DirsNew + snapshot.DirsChanged
+			total := changed + snapshot.DirsUnmodified
+			if total <= 0 {
+				continue
+			}
+			churn := float64(changed) / float64(total) * 100
+			m.Data[iterator.CurrentOffset] = append(m.Data[iterator.CurrentOffset], churn)
+		}
+	}
+}
+
+// DomainBlobsAdded is a metric of the data and tree blobs added to the repo by
+// the snapshots of a domain
+type DomainBlobsAdded struct {
+	metricData
+}
+
+// Init initializes the metric
+func (m *DomainBlobsAdded) Init(timeUnit string) {
+	m.supportsDomains = true
+	m.DateLayout = getDateLayoutForTimeUnit(timeUnit)
+	m.Name = "blobs added by"
+	m.Formatter = &AmountFormatter{}
+	m.aggregators = flowAggregators
+	m.kind = MetricKindCounter
+}
+
+// GetDefaultAggregator returns the default aggregator for metric m
+func (m *DomainBlobsAdded) GetDefaultAggregator() Aggregator {
+	return &Sum{}
+}
+
+// FetchDataFromDB fetches appropriate data from DB and appends values
+// for given timeUnit and timeLength. Backfilled snapshots are left out
+func (m *DomainBlobsAdded) FetchDataFromDB(db *DB, timeUnit string, timeLength int) {
+	iterator := m.newDateIterator(db, timeUnit, timeLength)
+	for date := iterator.Next(); date.Valid; date = iterator.Next() {
+		records := iterator.GetRecordsForDate("snapshot", m, date.Value)
+		m.AddDate(date.Value)
+		for obj := records.Next(); obj != nil; obj = records.Next() {
+			if backfilled(obj) {
+				continue
+			}
+			snapshot := obj.(*SnapshotSummary)
+			blobs := float64(snapshot.DataBlobs + snapshot.TreeBlobs)
+			m.Data[iterator.CurrentOffset] = append(m.Data[iterator.CurrentOffset], blobs)
+		}
+	}
+}
+
+// backfilled returns whether the snapshot record obj was written by Backfill, which
+// only knows the files and bytes processed of a snapshot and writes the rest as 0
+func backfilled(obj interface{}) bool {
+	return obj.(*SnapshotSummary).Source == SourceBackfill
+}
+
+// containsString returns whether values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// sizesPerSource returns the TotalSize of given records per source
+func sizesPerSource(records map[sourceKey]interface{}) map[sourceKey]float64 {
+	sizes := make(map[sourceKey]float64)
+	for key, obj := range records {
+		sizes[key] = fieldValue(obj, "TotalSize")
+	}
+	return sizes
+}
+
+// domainSizeRatio returns the total of sizes divided by the total of diskSizes
+// for given domain, or for all domains. Only domains with both sizes are included,
+// false is returned if there are none
+func domainSizeRatio(sizes map[sourceKey]float64, diskSizes map[sourceKey]float64, domain string) (float64, bool) {
+	var total, disk float64
+	for key, diskSize := range diskSizes {
+		size, ok := sizes[key]
+		if !ok || (domain != AllDomains && key.Domain != domain) {
+			continue
+		}
+		total += size
+		disk += diskSize
+	}
+	if disk <= 0 {
+		return 0, false
+	}
+	return total / disk, true
+}
+
+// repoSizeRatio returns the total of sizes divided by the total of repoSizes,
+// false is returned if either is not known
+func repoSizeRatio(sizes map[sourceKey]float64, repoSizes map[sourceKey]float64) (float64, bool) {
+	var total, repoTotal float64
+	for _, size := range sizes {
+		total += size
+	}
+	for _, size := range repoSizes {
+		repoTotal += size
+	}
+	if total <= 0 || repoTotal <= 0 {
+		return 0, false
+	}
+	return total / repoTotal, true
+}
+
+// DomainDiskSpaceStacked is a metric of how much space the backups of each domain
+// takes on disk, stacked to the total of all domains. With Share set, the space of
+// each domain is the percentage of the total
+type DomainDiskSpaceStacked struct {
+	metricData
+	Share bool
+
+	domains       []string
+	domainData    map[sourceKey][][]float64
+	domainInitial map[sourceKey][]float64
+}
+
+// Init initializes the metric
+func (m *DomainDiskSpaceStacked) Init(timeUnit string) {
+	m.supportsDomains = true
+	m.DateLayout = getDateLayoutForTimeUnit(timeUnit)
+	m.Name = "disk space per domain occupied by"
+	m.Formatter = &BytesFormatter{}
+	m.aggregators = stackedAggregators
+	m.kind = MetricKindGauge
+	if m.Share {
+		m.Name = "share of disk space per domain occupied by"
+		m.Formatter = &PercentFormatter{}
+	}
+	m.domainData = make(map[sourceKey][][]float64)
+	m.domainInitial = make(map[sourceKey][]float64)
+}
+
+// GetDefaultAggregator returns the default aggregator for metric m
+func (m *DomainDiskSpaceStacked) GetDefaultAggregator() Aggregator {
+	return &Last{}
+}
+
+// FetchDataFromDB fetches appropriate data from DB and appends values
+// for given timeUnit and timeLength
+func (m *DomainDiskSpaceStacked) FetchDataFromDB(db *DB, timeUnit string, timeLength int) {
+	iterator := m.newDateIterator(db, timeUnit, timeLength)
+	for key, obj := range iterator.GetLastRecordsPerSourceBefore("domain_raw_data", m) {
+		m.addDomain(key)
+		m.domainInitial[key] = []float64{fieldValue(obj, "TotalSize")}
+	}
+	for date := iterator.Next(); date.Valid; date = iterator.Next() {
+		records := iterator.GetRecordsForDate("domain_raw_data", m, date.Value)
+		m.AddDate(date.Value)
+		for key := range m.domainData {
+			m.domainData[key] = append(m.domainData[key], []float64{})
+		}
+		for obj := records.Next(); obj != nil; obj = records.Next() {
+			key := keyOf(obj)
+			m.addDomain(key)
+			values := m.domainData[key]
+			values[iterator.CurrentOffset] = append(values[iterator.CurrentOffset], fieldValue(obj, "TotalSize"))
+		}
+	}
+	sort.Strings(m.domains)
+}
+
+// addDomain adds the domain of key in the group and repo of key to metric m, if
+// it is not added already
+func (m *DomainDiskSpaceStacked) addDomain(key sourceKey) {
+	if _, ok := m.domainData[key]; ok {
+		return
+	}
+	if !containsString(m.domains, key.Domain) {
+		m.domains = append(m.domains, key.Domain)
+	}
+	m.domainData[key] = make([][]float64, len(m.Dates))
+}
+
+// GetSeriesNames returns the names of the domains metric m has values for
+func (m *DomainDiskSpaceStacked) GetSeriesNames() []string {
+	return m.domains
+}
+
+// GetSeriesValues returns the aggregated values of given domain for metric m
+func (m *DomainDiskSpaceStacked) GetSeriesValues(domain string, a Aggregator) []float64 {
+	values := m.aggregateSeries(domain, a)
+	if !m.Share {
+		return values
+	}
+
+	totals := m.sumSeries(a)
+	for i := range values {
+		if totals[i] > 0 {
+			values[i] = values[i] / totals[i] * 100
+		}
+	}
+	return values
+}
+
+// GetValues returns the total of all domains for metric m, or 100 for every
+// date with any data in share mode
+func (m *DomainDiskSpaceStacked) GetValues(a Aggregator) []float64 {
+	totals := m.sumSeries(a)
+	if m.Share {
+		for i := range totals {
+			if totals[i] > 0 {
+				totals[i] = 100
+			}
+		}
+	}
+	return totals
+}
+
+// aggregateSeries returns the aggregated values of given domain, the dates
+// without values are filled using the gap fill strategy of m, starting from the
+// last value before the first date. The values of a
+// domain in several groups or repos are summed
+func (m *DomainDiskSpaceStacked) aggregateSeries(domain string, a Aggregator) []float64 {
+	totals := newGaps(len(m.Dates))
+	for key, data := range m.domainData {
+		if key.Domain == domain {
+			sumWithGaps(totals, aggregateWithGaps(data, a, m.kind, m.gapFill, m.domainInitial[key]))
+		}
+	}
+	return totals
+}
+
+// sumSeries returns the sum of the aggregated values of all domains, a date is
+// only a gap if it is a gap for every domain
+func (m *DomainDiskSpaceStacked) sumSeries(a Aggregator) []float64 {
+	totals := newGaps(len(m.Dates))
+	for _, domain := range m.domains {
+		sumWithGaps(totals, m.aggregateSeries(domain, a))
+	}
+	return totals
+}
@@ -0,0 +1,200 @@
+package stats
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// insertDomainRawData inserts a domain_raw_data record of given domain of group
+// demo in repo repo into db, as if read from a csv file
+func insertDomainRawData(db *DB, domain string, size int64, date time.Time) {
+	db.InsertRecord("domain_raw_data", &DomainRawData{
+		TotalSize: size,
+
+		ID:     db.nextID(),
+		Group:  "demo",
+		Domain: domain,
+		Repo:   "repo",
+		Source: SourceLive,
+
+		GroupWithWildcard:  []string{"demo", AllGroups},
+		RepoWithWildcard:   []string{"repo", AllRepos},
+		DomainWithWildcard: []string{domain, AllDomains},
+
+		Date:          date,
+		HourString:    dateKey(date, TimeUnitHours),
+		DateString:    date.Format(getDateLayoutForTimeUnit(TimeUnitDays)),
+		WeekString:    dateKey(date, TimeUnitWeeks),
+		MonthString:   date.Format(getDateLayoutForTimeUnit(TimeUnitMonths)),
+		QuarterString: dateKey(date, TimeUnitQuarters),
+		YearString:    date.Format(getDateLayoutForTimeUnit(TimeUnitYears)),
+	})
+}
+
+func TestDomainDiskSpaceSumsAllDomains(t *testing.T) {
+	day := time.Date(2020, 3, 10, 0, 0, 0, 0, time.Local)
+
+	db := NewDB()
+	insertDomainRawData(db, "a", 100, day.AddDate(0, 0, -2).Add(12*time.Hour))
+	insertDomainRawData(db, "a", 110, day.Add(10*time.Hour))
+	insertDomainRawData(db, "b", 1000, day.Add(11*time.Hour))
+	insertDomainRawData(db, "b", 1200, day.AddDate(0, 0, 1).Add(9*time.Hour))
+
+	tests := []struct {
+		domain     string
+		aggregator Aggregator
+		want       []float64
+	}{
+		{AllDomains, &Last{}, []float64{100, 1110, 1310}},
+		{AllDomains, &Average{}, []float64{100, 1110, 1310}},
+		{AllDomains, &Max{}, []float64{100, 1110, 1310}},
+		{"a", &Last{}, []float64{100, 110, 110}},
+		{"b", &Last{}, []float64{0, 1000, 1200}},
+	}
+	for _, tt := range tests {
+		m, err := NewMetric("domain-disk-space", "repo", "demo", tt.domain, TimeUnitDays, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		m.SetGapFill(GapFillCarry)
+		m.SetDateRange(day.AddDate(0, 0, -1), day.AddDate(0, 0, 2))
+		m.FetchDataFromDB(db, TimeUnitDays, 0)
+
+		got := m.GetValues(tt.aggregator)
+		for i := range got {
+			if math.IsNaN(got[i]) {
+				got[i] = 0
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("domain %s, %T: got %v, want %v", tt.domain, tt.aggregator, got, tt.want)
+		}
+	}
+}
+
+func TestDomainDiskSpaceShareKeepsDomainsBackedUpBeforeRange(t *testing.T) {
+	day := time.Date(2020, 3, 10, 0, 0, 0, 0, time.Local)
+
+	db := NewDB()
+	insertDomainRawData(db, "a", 500, day.AddDate(0, 0, -5))
+	insertDomainRawData(db, "b", 1500, day.AddDate(0, 0, -5))
+	insertDomainRawData(db, "b", 500, day.AddDate(0, 0, -1))
+
+	m, err := NewMetric("domain-disk-space-share", "repo", "demo", AllDomains, TimeUnitDays, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.SetGapFill(GapFillCarry)
+	m.SetDateRange(day.AddDate(0, 0, -3), day)
+	m.FetchDataFromDB(db, TimeUnitDays, 0)
+
+	stacked := m.(StackedMetric)
+	if got := stacked.GetSeriesNames(); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Fatalf("got series %v, want [a b]", got)
+	}
+	tests := map[string][]float64{
+		"a": {25, 25, 50},
+		"b": {75, 75, 50},
+	}
+	for domain, want := range tests {
+		if got := stacked.GetSeriesValues(domain, &Last{}); !reflect.DeepEqual(got, want) {
+			t.Errorf("domain %s: got %v, want %v", domain, got, want)
+		}
+	}
+}
+
+// insertSnapshot inserts a snapshot summary of domain docs of group demo in repo
+// repo into db, as if read from a csv file, with given data added and duration
+func insertSnapshot(db *DB, snapshotID string, source string, date time.Time, dataAdded int, duration float64) {
+	db.InsertRecord("snapshot", &SnapshotSummary{
+		DataAdded:     dataAdded,
+		TotalDuration: duration,
+
+		SnapshotID: snapshotID,
+		Group:      "demo",
+		Domain:     "docs",
+		Repo:       "repo",
+		Source:     source,
+
+		GroupWithWildcard:  []string{"demo", AllGroups},
+		RepoWithWildcard:   []string{"repo", AllRepos},
+		DomainWithWildcard: []string{"docs", AllDomains},
+		Date:               date,
+		HourString:         dateKey(date, TimeUnitHours),
+		DateString:         date.Format(getDateLayoutForTimeUnit(TimeUnitDays)),
+		WeekString:         dateKey(date, TimeUnitWeeks),
+		MonthString:        date.Format(getDateLayoutForTimeUnit(TimeUnitMonths)),
+		QuarterString:      dateKey(date, TimeUnitQuarters),
+		YearString:         date.Format(getDateLayoutForTimeUnit(TimeUnitYears)),
+	})
+}
+
+func TestDomainBackupTimeLeavesOutBackfilledSnapshots(t *testing.T) {
+	day := time.Date(2020, 3, 10, 0, 0, 0, 0, time.Local)
+
+	db := NewDB()
+	insertSnapshot(db, "a", SourceLive, day.Add(1*time.Hour), 100, 60)
+	insertSnapshot(db, "b", SourceBackfill, day.Add(2*time.Hour), 0, 0)
+	insertSnapshot(db, "c", SourceLive, day.Add(3*time.Hour), 100, 60)
+
+	for name, want := range map[string]float64{"domain-backup-time": 60, "domain-data-added": 100} {
+		m, err := NewMetric(name, "repo", "demo", "docs", TimeUnitDays, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		m.SetDateRange(day, day.AddDate(0, 0, 1))
+		m.FetchDataFromDB(db, TimeUnitDays, 0)
+
+		if got := m.GetValues(&Average{}); !reflect.DeepEqual(got, []float64{want}) {
+			t.Errorf("%s: got %v, want [%v]", name, got, want)
+		}
+	}
+}
+
+func TestDomainDiskSpaceShareLeavesOutGaps(t *testing.T) {
+	day := time.Date(2020, 3, 10, 0, 0, 0, 0, time.Local)
+
+	db := NewDB()
+	insertDomainRawData(db, "a", 100, day.Add(time.Hour))
+	insertDomainRawData(db, "b", 300, day.Add(time.Hour))
+	insertDomainRawData(db, "b", 500, day.AddDate(0, 0, 1).Add(time.Hour))
+
+	m, err := NewMetric("domain-disk-space-share", "repo", "demo", AllDomains, TimeUnitDays, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.SetGapFill(GapFillGap)
+	m.SetDateRange(day, day.AddDate(0, 0, 3))
+	m.FetchDataFromDB(db, TimeUnitDays, 0)
+
+	stacked := m.(StackedMetric)
+	tests := map[string][]float64{
+		"a": {25, math.NaN(), math.NaN()},
+		"b": {75, 100, math.NaN()},
+		"":  {100, 100, math.NaN()},
+	}
+	for domain, want := range tests {
+		got := m.GetValues(&Last{})
+		if domain != "" {
+			got = stacked.GetSeriesValues(domain, &Last{})
+		}
+		if !equalWithGaps(got, want) {
+			t.Errorf("domain %q: got %v, want %v", domain, got, want)
+		}
+	}
+}
+
+// equalWithGaps returns whether a and b are equal, with gaps equal to each other
+func equalWithGaps(a []float64, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] && !(math.IsNaN(a[i]) && math.IsNaN(b[i])) {
+			return false
+		}
+	}
+	return true
+}
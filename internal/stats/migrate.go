@@ -0,0 +1,167 @@
+package stats
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/nattvara/dfb/internal/paths"
+)
+
+// Migrate brings the csv files in the stats directory of given group up to
+// the current schema version, see MigrateDir
+func Migrate(groupName string) ([]string, error) {
+	return MigrateDir(StatsDir(groupName))
+}
+
+// MigrateDir brings the csv files in given stats directory up to the current
+// schema version. Missing files are created with a version line and a header,
+// files written with an older schema, or without a header, are rewritten in
+// place with the current column order.
+//
+// Nothing is thrown away, the original content of a rewritten file is kept in a
+// .vN.bak file next to it (N being the old version) and rows that does not match
+// the old layout are moved to a .unmigrated file. Returns the paths of created
+// or rewritten files
+func MigrateDir(statsDir string) ([]string, error) {
+	var migrated []string
+
+	if err := os.MkdirAll(statsDir, 0755); err != nil {
+		return migrated, errors.New("failed to create stats directory. " + err.Error())
+	}
+
+	for _, schema := range csvSchemas {
+		path := statsDir + "/" + schema.Filename
+		changed, err := migrateFile(path, schema)
+		if err != nil {
+			return migrated, fmt.Errorf("failed to migrate %s. %s", path, err.Error())
+		}
+		if changed {
+			migrated = append(migrated, path)
+		}
+	}
+
+	return migrated, nil
+}
+
+// migrateFile migrates csv file at given path to the current version of given
+// schema, returns true if the file was created or rewritten
+func migrateFile(path string, schema *csvSchema) (bool, error) {
+	if !paths.Exists(path) {
+		file, err := os.Create(path)
+		if err != nil {
+			return false, err
+		}
+		defer file.Close()
+		return true, writeSchemaHeader(file, schema)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+
+	buffered := bufio.NewReader(bytes.NewReader(data))
+	version := readSchemaVersion(buffered)
+	if version == SchemaVersion {
+		return false, nil
+	}
+	if version > SchemaVersion {
+		return false, fmt.Errorf("file has schema version %v, this version of dfb supports up to %v", version, SchemaVersion)
+	}
+
+	reader := csv.NewReader(buffered)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return false, err
+	}
+
+	columns := schema.LegacyColumns
+	if version > 1 && len(records) > 0 {
+		columns = records[0]
+		records = records[1:]
+	}
+
+	var out bytes.Buffer
+	if err := writeSchemaHeader(&out, schema); err != nil {
+		return false, err
+	}
+
+	writer := csv.NewWriter(&out)
+	var unmigrated [][]string
+	for _, record := range records {
+		if len(record) != len(columns) {
+			unmigrated = append(unmigrated, record)
+			continue
+		}
+		writer.Write(convertRecord(record, columns, schema))
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return false, err
+	}
+
+	if err := ioutil.WriteFile(fmt.Sprintf("%s.v%v.bak", path, version), data, 0644); err != nil {
+		return false, err
+	}
+
+	if len(unmigrated) > 0 {
+		if err := appendRecords(path+".unmigrated", unmigrated); err != nil {
+			return false, err
+		}
+	}
+
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, out.Bytes(), 0644); err != nil {
+		return false, err
+	}
+	return true, os.Rename(tmp, path)
+}
+
+// convertRecord maps a record with given columns to the current column order
+// of given schema, columns without a value get the default of the schema
+func convertRecord(record []string, columns []string, schema *csvSchema) []string {
+	values := make(map[string]string)
+	for column, value := range schema.Defaults {
+		values[column] = value
+	}
+	for i, column := range columns {
+		values[column] = record[i]
+	}
+
+	var converted []string
+	for _, column := range schema.Columns {
+		converted = append(converted, values[column])
+	}
+	return converted
+}
+
+// writeSchemaHeader writes the version line and the header of given schema to w
+func writeSchemaHeader(w io.Writer, schema *csvSchema) error {
+	if _, err := fmt.Fprintf(w, "%s%v\n", schemaVersionPrefix, SchemaVersion); err != nil {
+		return err
+	}
+	writer := csv.NewWriter(w)
+	writer.Write(schema.Columns)
+	writer.Flush()
+	return writer.Error()
+}
+
+// appendRecords appends given records to the csv file at given path
+func appendRecords(path string, records [][]string) error {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.WriteAll(records)
+	return writer.Error()
+}
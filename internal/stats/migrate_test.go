@@ -0,0 +1,111 @@
+package stats
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrateDir(t *testing.T) {
+	const header = "#dfb-stats-schema:3\n" +
+		"total_size,total_file_count,total_blob_count,group,domain,repo,date,snapshot_id,source\n"
+
+	tests := []struct {
+		name           string
+		content        string
+		wantContent    string
+		wantBackup     string
+		wantUnmigrated string
+	}{
+		{
+			name: "v1",
+			content: "100,10,5,demo,docs,repo,2020-03-10T12:00:00+0100\n" +
+				"200,20,demo,docs,repo,2020-03-11T12:00:00+0100\n",
+			wantContent:    header + "100,10,5,demo,docs,repo,2020-03-10T12:00:00+0100,,live\n",
+			wantBackup:     "domain_raw_data.csv.v1.bak",
+			wantUnmigrated: "200,20,demo,docs,repo,2020-03-11T12:00:00+0100\n",
+		},
+		{
+			name: "v2",
+			content: "#dfb-stats-schema:2\n" +
+				"snapshot_id,total_size,total_file_count,total_blob_count,group,domain,repo,date\n" +
+				"3f2c8a91,100,10,5,demo,docs,repo,2020-03-10T12:00:00+0100\n" +
+				"9b7d1e04,200\n",
+			wantContent:    header + "100,10,5,demo,docs,repo,2020-03-10T12:00:00+0100,3f2c8a91,live\n",
+			wantBackup:     "domain_raw_data.csv.v2.bak",
+			wantUnmigrated: "9b7d1e04,200\n",
+		},
+		{
+			name:        "v3",
+			content:     header + "100,10,5,demo,docs,repo,2020-03-10T12:00:00+0100,3f2c8a91,backfill\n",
+			wantContent: header + "100,10,5,demo,docs,repo,2020-03-10T12:00:00+0100,3f2c8a91,backfill\n",
+		},
+	}
+
+	for _, tt := range tests {
+		dir, err := ioutil.TempDir("", "dfb-stats-migrate")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		path := filepath.Join(dir, domainRawDataSchema.Filename)
+		if err := ioutil.WriteFile(path, []byte(tt.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		migrated, err := MigrateDir(dir)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		var rewritten bool
+		for _, m := range migrated {
+			rewritten = rewritten || m == path
+		}
+		if want := tt.wantBackup != ""; rewritten != want {
+			t.Errorf("%s: got %s rewritten %v, want %v", tt.name, path, rewritten, want)
+		}
+
+		if got := readFile(t, path); got != tt.wantContent {
+			t.Errorf("%s: got content %q, want %q", tt.name, got, tt.wantContent)
+		}
+
+		if tt.wantBackup != "" {
+			if got := readFile(t, filepath.Join(dir, tt.wantBackup)); got != tt.content {
+				t.Errorf("%s: got backup %q, want the original content %q", tt.name, got, tt.content)
+			}
+		} else if backups, _ := filepath.Glob(path + ".v*.bak"); len(backups) > 0 {
+			t.Errorf("%s: got backups %v of a file already at the current version, want none", tt.name, backups)
+		}
+
+		unmigrated := path + ".unmigrated"
+		if tt.wantUnmigrated != "" {
+			if got := readFile(t, unmigrated); got != tt.wantUnmigrated {
+				t.Errorf("%s: got unmigrated rows %q, want %q", tt.name, got, tt.wantUnmigrated)
+			}
+		} else if _, err := os.Stat(unmigrated); err == nil {
+			t.Errorf("%s: got %s, want no unmigrated rows", tt.name, unmigrated)
+		}
+
+		migrated, err = MigrateDir(dir)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(migrated) > 0 {
+			t.Errorf("%s: got %v migrated again, want nothing to migrate", tt.name, migrated)
+		}
+		if got := readFile(t, path); got != tt.wantContent {
+			t.Errorf("%s: got content %q after migrating again, want %q", tt.name, got, tt.wantContent)
+		}
+	}
+}
+
+// readFile returns the content of the file at given path
+func readFile(t *testing.T, path string) string {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
@@ -0,0 +1,201 @@
+package stats
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/nattvara/dfb/internal/paths"
+)
+
+const (
+	// gib is the number of bytes in a GiB, the unit storage is priced in
+	gib = 1 << 30
+
+	// hoursPerMonth is the number of hours in an average month, the unit of
+	// time storage is priced for
+	hoursPerMonth = 365.25 * 24 / 12
+)
+
+// PricedMetric is a Metric of the cost of storing data, priced with the Pricing
+// of its repo
+type PricedMetric interface {
+	Metric
+	GetPricing() (*Pricing, error)
+}
+
+// checkPricing returns an error if m is a PricedMetric whose pricing could not
+// be loaded
+func checkPricing(m Metric) error {
+	if priced, ok := m.(PricedMetric); ok {
+		_, err := priced.GetPricing()
+		return err
+	}
+	return nil
+}
+
+// Pricing is the price of storing data in a repo, per GiB and month. The price
+// can be tiered, the first tier prices the first bytes stored and so on
+type Pricing struct {
+	Currency string
+	Tiers    []PricingTier
+}
+
+// PricingTier is the price per GiB-month for a number of bytes of a Pricing
+type PricingTier struct {
+	// Size is the number of bytes priced by the tier, 0 for all remaining bytes
+	Size  float64
+	Price float64
+}
+
+// PricingPath returns the path to the pricing file of repo in group with given names
+func PricingPath(groupName string, repoName string) string {
+	return fmt.Sprintf("%s/%s/pricing/%s", paths.DFB(), groupName, repoName)
+}
+
+// LoadPricing reads the pricing of repo in group with given names. The pricing
+// file has a line with the currency and a line per tier, with the size priced
+// by the tier, or * for all remaining bytes, and the price per GiB-month
+//
+//	currency USD
+//	tier 50TiB 0.023
+//	tier * 0.022
+func LoadPricing(groupName string, repoName string) (*Pricing, error) {
+	data, err := ioutil.ReadFile(PricingPath(groupName, repoName))
+	if os.IsNotExist(err) {
+		return nil, errors.New("no pricing is set for repo " + repoName + ", set it with the pricing command")
+	}
+	if err != nil {
+		return nil, errors.New("failed to read pricing. " + err.Error())
+	}
+
+	p := &Pricing{}
+	for i, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		switch {
+		case fields[0] == "currency" && len(fields) == 2:
+			p.Currency = fields[1]
+		case fields[0] == "tier" && len(fields) == 3:
+			tier, err := ParsePricingTier(fields[1] + ":" + fields[2])
+			if err != nil {
+				return nil, err
+			}
+			p.Tiers = append(p.Tiers, tier)
+		default:
+			return nil, fmt.Errorf("invalid line %v in pricing of repo %s: %s", i+1, repoName, line)
+		}
+	}
+
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// ParsePricingTier parses a tier such as 50TiB:0.023, or a price such as 0.023
+// for all remaining bytes
+func ParsePricingTier(tier string) (PricingTier, error) {
+	size, price := "*", tier
+	if i := strings.LastIndex(tier, ":"); i >= 0 {
+		size, price = tier[:i], tier[i+1:]
+	}
+
+	var t PricingTier
+	var err error
+	if size != "*" {
+		if t.Size, err = ParseBytes(size); err != nil {
+			return t, err
+		}
+		if t.Size <= 0 {
+			return t, errors.New("the size of tier " + tier + " must be larger than 0")
+		}
+	}
+	if t.Price, err = strconv.ParseFloat(price, 64); err != nil || t.Price < 0 {
+		return t, errors.New("invalid price in tier " + tier + ", expected a price per GiB-month such as 0.023")
+	}
+	return t, nil
+}
+
+// Validate returns an error if Pricing p has no tiers, or a tier after the one
+// pricing all remaining bytes
+func (p *Pricing) Validate() error {
+	if len(p.Tiers) == 0 {
+		return errors.New("pricing has no tiers")
+	}
+	for i, tier := range p.Tiers {
+		if tier.Size == 0 && i < len(p.Tiers)-1 {
+			return errors.New("only the last tier of a pricing can price all remaining bytes")
+		}
+	}
+	return nil
+}
+
+// Save writes Pricing p as the pricing of repo in group with given names
+func (p *Pricing) Save(groupName string, repoName string) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	path := PricingPath(groupName, repoName)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return errors.New("failed to create pricing directory. " + err.Error())
+	}
+	if err := ioutil.WriteFile(path, []byte(p.String()), 0644); err != nil {
+		return errors.New("failed to write pricing. " + err.Error())
+	}
+	return nil
+}
+
+// String returns Pricing p in the format of a pricing file
+func (p *Pricing) String() string {
+	var out strings.Builder
+	if p.Currency != "" {
+		out.WriteString("currency " + p.Currency + "\n")
+	}
+	for _, tier := range p.Tiers {
+		size := "*"
+		if tier.Size > 0 {
+			size = formatExactBytes(tier.Size)
+		}
+		out.WriteString(fmt.Sprintf("tier %s %s\n", size, strconv.FormatFloat(tier.Price, 'f', -1, 64)))
+	}
+	return out.String()
+}
+
+// MonthlyCost returns the cost of storing given bytes for a month with Pricing p,
+// bytes beyond the last tier are priced by the last tier
+func (p *Pricing) MonthlyCost(bytes float64) float64 {
+	cost := 0.0
+	for i, tier := range p.Tiers {
+		priced := bytes
+		if tier.Size > 0 && tier.Size < bytes && i < len(p.Tiers)-1 {
+			priced = tier.Size
+		}
+		cost += priced / gib * tier.Price
+		bytes -= priced
+		if bytes <= 0 {
+			break
+		}
+	}
+	return cost
+}
+
+// formatExactBytes formats bytes in the largest unit of ParseBytes it is a whole
+// number of, so that it parses back to the same number of bytes
+func formatExactBytes(bytes float64) string {
+	for _, unit := range []string{"PiB", "TiB", "GiB", "MiB", "KiB", "PB", "TB", "GB", "MB", "KB"} {
+		size := byteUnits[strings.ToLower(unit)]
+		if bytes >= size && bytes == float64(int64(bytes/size))*size {
+			return strconv.FormatInt(int64(bytes/size), 10) + unit
+		}
+	}
+	return strconv.FormatFloat(bytes, 'f', -1, 64) + "B"
+}
@@ -0,0 +1,151 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// prometheusGauge is a gauge written by WritePrometheus, with a sample of given
+// field of the latest record of each source in table
+type prometheusGauge struct {
+	Name  string
+	Help  string
+	Table string
+	Value func(obj interface{}) float64
+
+	// LiveOnly is whether the sample is taken from the latest snapshot recorded
+	// during backup, as backfilled snapshots have no data added or duration
+	LiveOnly bool
+}
+
+// prometheusGauges are the gauges written by WritePrometheus
+var prometheusGauges = []prometheusGauge{
+	{
+		Name:  "dfb_last_snapshot_timestamp_seconds",
+		Help:  "Time of the last snapshot of a domain in a repo, in seconds since the epoch.",
+		Table: "snapshot",
+		Value: func(obj interface{}) float64 { return float64(recordDate(obj).Unix()) },
+	},
+	{
+		Name:  "dfb_last_snapshot_data_added_bytes",
+		Help:  "Data added to the repo by the last snapshot of a domain.",
+		Table: "snapshot",
+		Value: func(obj interface{}) float64 { return fieldValue(obj, "DataAdded") },
+
+		LiveOnly: true,
+	},
+	{
+		Name:  "dfb_last_snapshot_duration_seconds",
+		Help:  "Time it took to take the last snapshot of a domain.",
+		Table: "snapshot",
+		Value: func(obj interface{}) float64 { return fieldValue(obj, "TotalDuration") },
+
+		LiveOnly: true,
+	},
+	{
+		Name:  "dfb_repo_size_bytes",
+		Help:  "Size of a repo on disk, the last time it was measured.",
+		Table: "repo_raw_data",
+		Value: func(obj interface{}) float64 { return fieldValue(obj, "TotalSize") },
+	},
+	{
+		Name:  "dfb_domain_size_bytes",
+		Help:  "Size on disk of the data of a domain in a repo, the last time it was measured.",
+		Table: "domain_raw_data",
+		Value: func(obj interface{}) float64 { return fieldValue(obj, "TotalSize") },
+	},
+	{
+		Name:  "dfb_domain_restore_size_bytes",
+		Help:  "Size of a domain when restored from a repo, the last time it was measured.",
+		Table: "domain_restore_size",
+		Value: func(obj interface{}) float64 { return fieldValue(obj, "TotalSize") },
+	},
+}
+
+// WritePrometheus writes the latest stats in db to w in the Prometheus text
+// format, as read by the textfile collector of node_exporter. Every gauge has a
+// sample per group and repo, and per domain for the stats of domains
+func WritePrometheus(w io.Writer, db *DB) error {
+	var out strings.Builder
+	for _, gauge := range prometheusGauges {
+		fmt.Fprintf(&out, "# HELP %s %s\n", gauge.Name, gauge.Help)
+		fmt.Fprintf(&out, "# TYPE %s gauge\n", gauge.Name)
+
+		latest := db.latestRecords(gauge.Table)
+		if gauge.LiveOnly {
+			latest = db.latestRecordsWhere(gauge.Table, func(obj interface{}) bool { return !backfilled(obj) })
+		}
+		var keys []sourceKey
+		for key := range latest {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			a, b := keys[i], keys[j]
+			if a.Group != b.Group {
+				return a.Group < b.Group
+			}
+			if a.Repo != b.Repo {
+				return a.Repo < b.Repo
+			}
+			return a.Domain < b.Domain
+		})
+
+		for _, key := range keys {
+			value := strconv.FormatFloat(gauge.Value(latest[key]), 'f', -1, 64)
+			fmt.Fprintf(&out, "%s{%s} %s\n", gauge.Name, prometheusLabels(key), value)
+		}
+	}
+
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+// prometheusLabels returns the labels of a sample of the source with given key
+func prometheusLabels(key sourceKey) string {
+	labels := []string{
+		fmt.Sprintf(`group="%s"`, escapePrometheusLabel(key.Group)),
+		fmt.Sprintf(`repo="%s"`, escapePrometheusLabel(key.Repo)),
+	}
+	if key.Domain != "" {
+		labels = append(labels, fmt.Sprintf(`domain="%s"`, escapePrometheusLabel(key.Domain)))
+	}
+	return strings.Join(labels, ",")
+}
+
+// escapePrometheusLabel escapes value for use as a label value in the
+// Prometheus text format
+func escapePrometheusLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// latestRecords returns the latest record of each source in given table of db
+func (db *DB) latestRecords(table string) map[sourceKey]interface{} {
+	return db.latestRecordsWhere(table, func(obj interface{}) bool { return true })
+}
+
+// latestRecordsWhere returns the latest record of each source in given table
+// of db, of the records that keep returns true for
+func (db *DB) latestRecordsWhere(table string, keep func(obj interface{}) bool) map[sourceKey]interface{} {
+	txn := db.memdb.Txn(false)
+	defer txn.Abort()
+
+	records, err := txn.Get(table, "id")
+	if err != nil {
+		panic("failed to fetch records from db. " + err.Error())
+	}
+
+	latest := make(map[sourceKey]interface{})
+	for obj := records.Next(); obj != nil; obj = records.Next() {
+		if !keep(obj) {
+			continue
+		}
+		key := keyOf(obj)
+		if last, ok := latest[key]; !ok || recordDate(obj).After(recordDate(last)) {
+			latest[key] = obj
+		}
+	}
+	return latest
+}
@@ -0,0 +1,32 @@
+package stats
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWritePrometheusTakesSnapshotStatsFromLiveSnapshots(t *testing.T) {
+	day := time.Date(2020, 3, 10, 0, 0, 0, 0, time.Local)
+
+	db := NewDB()
+	insertSnapshot(db, "a", SourceLive, day.Add(1*time.Hour), 100, 60)
+	insertSnapshot(db, "b", SourceBackfill, day.Add(2*time.Hour), 0, 0)
+
+	var out strings.Builder
+	if err := WritePrometheus(&out, db); err != nil {
+		t.Fatal(err)
+	}
+
+	labels := `{group="demo",repo="repo",domain="docs"}`
+	for _, want := range []string{
+		"dfb_last_snapshot_timestamp_seconds" + labels + " " + strconv.FormatInt(day.Add(2*time.Hour).Unix(), 10),
+		"dfb_last_snapshot_data_added_bytes" + labels + " 100",
+		"dfb_last_snapshot_duration_seconds" + labels + " 60",
+	} {
+		if !strings.Contains(out.String(), want+"\n") {
+			t.Errorf("got\n%s\nwant a line %s", out.String(), want)
+		}
+	}
+}
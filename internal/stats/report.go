@@ -0,0 +1,66 @@
+package stats
+
+import (
+	"sort"
+	"time"
+)
+
+// DomainSummary is a summary of the backups of a domain of a group to a repo,
+// the snapshots taken and the data they added since a date, and the latest size
+// of the domain. LastSnapshot is zero if the domain was never backed up
+type DomainSummary struct {
+	Repo         string
+	Domain       string
+	Snapshots    int
+	DataAdded    float64
+	Size         float64
+	RestoreSize  float64
+	LastSnapshot time.Time
+}
+
+// SummarizeDomains returns a summary of each domain of group with given name in
+// each repo it was backed up to, with the snapshots taken since given date,
+// ordered by repo and domain
+func SummarizeDomains(db *DB, group string, since time.Time) []DomainSummary {
+	summaries := make(map[sourceKey]*DomainSummary)
+	summaryOf := func(key sourceKey) *DomainSummary {
+		if _, ok := summaries[key]; !ok {
+			summaries[key] = &DomainSummary{Repo: key.Repo, Domain: key.Domain}
+		}
+		return summaries[key]
+	}
+
+	for key, snapshots := range db.snapshotsPerDomain(group, AllRepos, AllDomains) {
+		summary := summaryOf(key)
+		for _, snapshot := range snapshots {
+			if !snapshot.Date.Before(since) {
+				summary.Snapshots++
+				summary.DataAdded += float64(snapshot.DataAdded)
+			}
+		}
+		summary.LastSnapshot = snapshots[len(snapshots)-1].Date
+	}
+
+	for key, obj := range db.latestRecords("domain_raw_data") {
+		if key.Group == group {
+			summaryOf(key).Size = fieldValue(obj, "TotalSize")
+		}
+	}
+	for key, obj := range db.latestRecords("domain_restore_size") {
+		if key.Group == group {
+			summaryOf(key).RestoreSize = fieldValue(obj, "TotalSize")
+		}
+	}
+
+	var res []DomainSummary
+	for _, summary := range summaries {
+		res = append(res, *summary)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Repo != res[j].Repo {
+			return res[i].Repo < res[j].Repo
+		}
+		return res[i].Domain < res[j].Domain
+	})
+	return res
+}
@@ -0,0 +1,234 @@
+package stats
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nattvara/dfb/internal/paths"
+)
+
+const (
+	// StateOK is the state of a domain backed up more recently than its warning threshold
+	StateOK = "ok"
+
+	// StateWarning is the state of a domain not backed up for longer than its warning threshold
+	StateWarning = "warning"
+
+	// StateCritical is the state of a domain not backed up for longer than its critical
+	// threshold, or never backed up
+	StateCritical = "critical"
+
+	// DefaultWarningAge is the warning threshold used when none is configured
+	DefaultWarningAge = 2 * 24 * time.Hour
+
+	// DefaultCriticalAge is the critical threshold used when none is configured
+	DefaultCriticalAge = 7 * 24 * time.Hour
+)
+
+// states are the states of a domain ordered from best to worst, the index of a
+// state is the exit code of the status command
+var states = []string{
+	StateOK,
+	StateWarning,
+	StateCritical,
+}
+
+// StateExitCode returns the exit code for state, following the convention of
+// monitoring plugins where 0 is ok, 1 is warning and 2 is critical
+func StateExitCode(state string) int {
+	for i, s := range states {
+		if s == state {
+			return i
+		}
+	}
+	return len(states)
+}
+
+// WorseState returns the worse of states a and b
+func WorseState(a string, b string) string {
+	if StateExitCode(b) > StateExitCode(a) {
+		return b
+	}
+	return a
+}
+
+// Threshold is how old the last snapshot of a domain can be before it is a
+// warning or critical
+type Threshold struct {
+	Warning  time.Duration
+	Critical time.Duration
+}
+
+// Thresholds are the thresholds of the domains of a group, domains without a
+// threshold of their own use Default
+type Thresholds struct {
+	Default Threshold
+	Domains map[string]Threshold
+}
+
+// ThresholdsPath returns the path to the thresholds file of group with given name
+func ThresholdsPath(groupName string) string {
+	return fmt.Sprintf("%s/%s/thresholds", paths.DFB(), groupName)
+}
+
+// LoadThresholds reads the thresholds of group with given name. The thresholds
+// file has a line per domain with the warning and critical age, such as
+// "photos 7d 30d", and the line of * sets the default of the group. Domains
+// without a line, or all domains if there is no file, use DefaultWarningAge and
+// DefaultCriticalAge
+func LoadThresholds(groupName string) (*Thresholds, error) {
+	t := &Thresholds{
+		Default: Threshold{Warning: DefaultWarningAge, Critical: DefaultCriticalAge},
+		Domains: make(map[string]Threshold),
+	}
+
+	data, err := ioutil.ReadFile(ThresholdsPath(groupName))
+	if os.IsNotExist(err) {
+		return t, nil
+	}
+	if err != nil {
+		return nil, errors.New("failed to read thresholds. " + err.Error())
+	}
+
+	for i, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("invalid line %v in thresholds of group %s: %s", i+1, groupName, line)
+		}
+
+		threshold, err := ParseThreshold(fields[1], fields[2])
+		if err != nil {
+			return nil, err
+		}
+		if fields[0] == "*" {
+			t.Default = threshold
+		} else {
+			t.Domains[fields[0]] = threshold
+		}
+	}
+	return t, nil
+}
+
+// ParseThreshold parses given warning and critical ages into a Threshold
+func ParseThreshold(warning string, critical string) (Threshold, error) {
+	var t Threshold
+	var err error
+	if t.Warning, err = ParseAge(warning); err != nil {
+		return t, err
+	}
+	if t.Critical, err = ParseAge(critical); err != nil {
+		return t, err
+	}
+	if t.Critical < t.Warning {
+		return t, errors.New("the critical age " + critical + " must not be less than the warning age " + warning)
+	}
+	return t, nil
+}
+
+// ParseAge parses an age such as 12h, 3d or 2w, where d is days and w is weeks.
+// Other units of time.ParseDuration, such as 90m, are supported as well
+func ParseAge(age string) (time.Duration, error) {
+	units := map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour}
+	for suffix, unit := range units {
+		if strings.HasSuffix(age, suffix) {
+			n, err := strconv.ParseFloat(strings.TrimSuffix(age, suffix), 64)
+			if err != nil || n < 0 {
+				break
+			}
+			return time.Duration(n * float64(unit)), nil
+		}
+	}
+
+	d, err := time.ParseDuration(age)
+	if err != nil || d < 0 {
+		return 0, errors.New("invalid age " + age + ", expected an age such as 12h, 3d or 2w")
+	}
+	return d, nil
+}
+
+// ForDomain returns the threshold of domain with given name
+func (t *Thresholds) ForDomain(domain string) Threshold {
+	if threshold, ok := t.Domains[domain]; ok {
+		return threshold
+	}
+	return t.Default
+}
+
+// Freshness is how long ago a domain of a group was last backed up to a repo,
+// LastSnapshot is zero if it never was
+type Freshness struct {
+	Group        string
+	Repo         string
+	Domain       string
+	LastSnapshot time.Time
+	Age          time.Duration
+	Threshold    Threshold
+	State        string
+}
+
+// CheckFreshness returns the Freshness of every domain of a group in every repo
+// of the group, using the snapshots in db and given thresholds, at time now. A
+// domain that was never backed up to a repo is critical
+func CheckFreshness(db *DB, group string, repos []string, domains []string, thresholds *Thresholds, now time.Time) []Freshness {
+	last := db.lastSnapshots(group)
+
+	var res []Freshness
+	for _, repo := range repos {
+		for _, domain := range domains {
+			f := Freshness{
+				Group:     group,
+				Repo:      repo,
+				Domain:    domain,
+				Threshold: thresholds.ForDomain(domain),
+				State:     StateCritical,
+			}
+
+			if date, ok := last[sourceKey{Group: group, Repo: repo, Domain: domain}]; ok {
+				f.LastSnapshot = date
+				f.Age = now.Sub(date)
+				switch {
+				case f.Age > f.Threshold.Critical:
+					f.State = StateCritical
+				case f.Age > f.Threshold.Warning:
+					f.State = StateWarning
+				default:
+					f.State = StateOK
+				}
+			}
+			res = append(res, f)
+		}
+	}
+	return res
+}
+
+// lastSnapshots returns the date of the latest snapshot of each repo and domain
+// of group with given name in db
+func (db *DB) lastSnapshots(group string) map[sourceKey]time.Time {
+	last := make(map[sourceKey]time.Time)
+	for key, obj := range db.latestRecords("snapshot") {
+		if key.Group == group {
+			last[key] = recordDate(obj)
+		}
+	}
+	return last
+}
+
+// FormatAge formats age in the largest unit that makes sense, eg. 12 days
+func FormatAge(age time.Duration) string {
+	switch {
+	case age < time.Hour:
+		return fmt.Sprintf("%v min", int(age.Minutes()))
+	case age < 48*time.Hour:
+		return fmt.Sprintf("%v h", int(age.Hours()))
+	default:
+		return fmt.Sprintf("%v days", int(age.Hours()/24))
+	}
+}
@@ -0,0 +1,192 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"text/tabwriter"
+	"unicode/utf8"
+)
+
+const (
+	// TermChartHeight is the number of lines the bars of a TermChart are drawn on
+	TermChartHeight = 12
+
+	// TermChartDefaultWidth is the width of a TermChart when the width of the terminal is not known
+	TermChartDefaultWidth = 80
+
+	// termChartMaxBarWidth is the widest a single bar of a TermChart is drawn
+	termChartMaxBarWidth = 8
+)
+
+// termBlocks are the characters used to draw the top of a bar, in eighths of a line
+var termBlocks = []rune{' ', '▁', '▂', '▃', '▄', '▅', '▆', '▇', '█'}
+
+// TermChart is a bar chart for a Metric drawn with unicode characters, for
+// viewing metrics in a terminal
+type TermChart struct {
+	Metric     Metric
+	Aggregator Aggregator
+	Width      int
+}
+
+// Write draws TermChart c to w
+func (c *TermChart) Write(w io.Writer) error {
+	values := c.Metric.GetValues(c.Aggregator)
+	formatter := c.Metric.GetFormatter()
+
+	width := c.Width
+	if width <= 0 {
+		width = TermChartDefaultWidth
+	}
+
+	low, high := 0.0, 0.0
+	for _, value := range values {
+		if math.IsNaN(value) {
+			continue
+		}
+		low = math.Min(low, value)
+		high = math.Max(high, value)
+	}
+
+	axisLabels := map[int]string{
+		TermChartHeight - 1: formatter.Format(high),
+		TermChartHeight / 2: formatter.Format(low + (high-low)/2),
+		0:                   formatter.Format(low),
+	}
+	labelWidth := 0
+	for _, label := range axisLabels {
+		labelWidth = maxInt(labelWidth, utf8.RuneCountInString(label))
+	}
+
+	barWidth := 1
+	if len(values) > 0 {
+		barWidth = (width - labelWidth - 2) / len(values)
+	}
+	barWidth = maxInt(1, minInt(barWidth, termChartMaxBarWidth))
+	gap := ""
+	if barWidth > 2 {
+		barWidth--
+		gap = " "
+	}
+
+	heights := make([]int, len(values))
+	for i, value := range values {
+		if high > low && !math.IsNaN(value) {
+			heights[i] = int(math.Round((value - low) / (high - low) * TermChartHeight * 8))
+		}
+	}
+
+	var out strings.Builder
+	out.WriteString(c.Metric.GetTitle() + "\n\n")
+
+	for row := TermChartHeight - 1; row >= 0; row-- {
+		axis := "│"
+		if _, ok := axisLabels[row]; ok {
+			axis = "┤"
+		}
+		out.WriteString(fmt.Sprintf("%*s %s", labelWidth, axisLabels[row], axis))
+		for _, height := range heights {
+			level := minInt(maxInt(height-row*8, 0), 8)
+			out.WriteString(gap + strings.Repeat(string(termBlocks[level]), barWidth))
+		}
+		out.WriteString("\n")
+	}
+
+	columnWidth := barWidth + len(gap)
+	out.WriteString(fmt.Sprintf("%*s └%s\n", labelWidth, "", strings.Repeat("─", columnWidth*len(values))))
+	out.WriteString(strings.Repeat(" ", labelWidth+2) + c.xAxisLabels(columnWidth, len(gap)) + "\n")
+
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+// xAxisLabels returns a line with the labels of the dates of TermChart c, labels
+// are left out where there is not enough room for them
+func (c *TermChart) xAxisLabels(columnWidth int, offset int) string {
+	line := []rune(strings.Repeat(" ", columnWidth*len(c.Metric.GetLabels())+offset))
+	next := 0
+	for i, date := range c.Metric.GetLabels() {
+		label := []rune(date.Format(c.Metric.GetDateLayout()))
+		start := i*columnWidth + offset
+		if start < next {
+			continue
+		}
+		for len(line) < start+len(label) {
+			line = append(line, ' ')
+		}
+		copy(line[start:], label)
+		next = start + len(label) + 1
+	}
+	return strings.TrimRight(string(line), " ")
+}
+
+// TermTable is a table of the values of a Metric, with one row per date
+type TermTable struct {
+	Metric     Metric
+	Aggregator Aggregator
+}
+
+// Write writes TermTable t to w, a StackedMetric gets a column per series
+// followed by the total
+func (t *TermTable) Write(w io.Writer) error {
+	labels := t.Metric.GetLabels()
+	formatter := &gapFormatter{t.Metric.GetFormatter()}
+
+	headers := []string{}
+	columns := [][]float64{}
+	if stacked, ok := t.Metric.(StackedMetric); ok {
+		for _, name := range stacked.GetSeriesNames() {
+			headers = append(headers, strings.ToUpper(name))
+			columns = append(columns, stacked.GetSeriesValues(name, t.Aggregator))
+		}
+		headers = append(headers, "TOTAL")
+	} else {
+		headers = append(headers, "VALUE")
+	}
+	columns = append(columns, t.Metric.GetValues(t.Aggregator))
+
+	cells := make([][]string, len(labels)+1)
+	cells[0] = append([]string{"DATE"}, headers...)
+	for i := range labels {
+		cells[i+1] = []string{labels[i].Format(t.Metric.GetDateLayout())}
+		for _, column := range columns {
+			cells[i+1] = append(cells[i+1], formatter.Format(column[i]))
+		}
+	}
+
+	widths := make([]int, len(cells[0]))
+	for _, row := range cells {
+		for j, cell := range row {
+			widths[j] = maxInt(widths[j], utf8.RuneCountInString(cell))
+		}
+	}
+
+	fmt.Fprintf(w, "%s\n\n", t.Metric.GetTitle())
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, row := range cells {
+		line := row[0]
+		for j := 1; j < len(row); j++ {
+			line += fmt.Sprintf("\t%*s", widths[j], row[j])
+		}
+		fmt.Fprintln(tw, line)
+	}
+	return tw.Flush()
+}
+
+// minInt returns the smaller of a and b
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// maxInt returns the larger of a and b
+func maxInt(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
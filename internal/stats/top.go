@@ -0,0 +1,167 @@
+package stats
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+)
+
+// Ranking is a metric aggregated per domain over a window of time, sorted
+// with the largest value first
+type Ranking struct {
+	Title     string
+	Since     time.Time
+	Formatter Formatter
+	Domains   []RankedDomain
+}
+
+// RankedDomain is the aggregated value of a metric for a domain in a Ranking
+type RankedDomain struct {
+	Domain    string  `json:"domain"`
+	Value     float64 `json:"value"`
+	Formatted string  `json:"formatted"`
+}
+
+// NewRanking aggregates metric with given name for each of given domains over
+// all values recorded from since until today, and ranks the domains. If
+// aggregatorName is empty the default aggregator of the metric is used. At most
+// limit domains are kept, or all if limit is 0
+func NewRanking(db *DB, name string, repo string, group string, domains []string, since time.Time, aggregatorName string, limit int) (*Ranking, error) {
+	today := time.Now()
+	start := time.Date(since.Year(), since.Month(), since.Day(), 0, 0, 0, 0, time.Local)
+	if start.After(today) {
+		return nil, errors.New("cannot rank domains from a date in the future")
+	}
+	days := int(today.Sub(start).Hours() / 24)
+
+	r := &Ranking{Since: start, Domains: []RankedDomain{}}
+
+	var aggregator Aggregator
+	for _, domain := range domains {
+		m, err := NewMetric(name, repo, group, domain, TimeUnitDays, aggregatorName)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkPricing(m); err != nil {
+			return nil, err
+		}
+		if _, ok := m.(StackedMetric); ok || !m.SupportsDomains() {
+			return nil, errors.New("metric " + name + " cannot be ranked by domain")
+		}
+
+		if aggregator == nil {
+			aggregator = m.GetDefaultAggregator()
+			if aggregatorName != "" {
+				if aggregator, err = NewAggregator(aggregatorName); err != nil {
+					return nil, err
+				}
+				if !SupportsAggregator(m, aggregatorName) {
+					return nil, errors.New("aggregator " + aggregatorName + " does not make sense for metric " + name)
+				}
+			}
+			r.Formatter = m.GetFormatter()
+			r.Title = fmt.Sprintf(
+				"%s %s domains in %s of %s since %s",
+				GetAggregatorName(aggregator),
+				m.GetName(),
+				groupTitle(group),
+				repoTitle(repo),
+				start.Format("2006-01-02"),
+			)
+		}
+
+		m.FetchDataFromDB(db, TimeUnitDays, days)
+
+		var values []float64
+		if m.GetKind() == MetricKindGauge {
+			// the size on each day, summed over groups and repos, rather than
+			// every size recorded, which would only be of one of them
+			for _, value := range m.GetValues(&Last{}) {
+				if !math.IsNaN(value) {
+					values = append(values, value)
+				}
+			}
+		} else {
+			for _, raw := range m.GetRawValues() {
+				values = append(values, raw...)
+			}
+		}
+		value := aggregator.Aggregate([]float64{}, values)[0]
+
+		r.Domains = append(r.Domains, RankedDomain{
+			Domain:    domain,
+			Value:     value,
+			Formatted: r.Formatter.Format(value),
+		})
+	}
+
+	sort.SliceStable(r.Domains, func(i, j int) bool {
+		return r.Domains[i].Value > r.Domains[j].Value
+	})
+	if limit > 0 && len(r.Domains) > limit {
+		r.Domains = r.Domains[:limit]
+	}
+
+	return r, nil
+}
+
+// WriteTable writes Ranking r to w as a table
+func (r *Ranking) WriteTable(w io.Writer) error {
+	fmt.Fprintf(w, "%s\n\n", r.Title)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "#\tDOMAIN\tVALUE")
+	for i, domain := range r.Domains {
+		fmt.Fprintf(tw, "%v\t%s\t%s\n", i+1, domain.Domain, domain.Formatted)
+	}
+	return tw.Flush()
+}
+
+// WriteBars writes Ranking r to w as a horizontal bar chart that fits in
+// given width
+func (r *Ranking) WriteBars(w io.Writer, width int) error {
+	if width <= 0 {
+		width = TermChartDefaultWidth
+	}
+
+	nameWidth, valueWidth := 0, 0
+	high := 0.0
+	for _, domain := range r.Domains {
+		nameWidth = maxInt(nameWidth, utf8.RuneCountInString(domain.Domain))
+		valueWidth = maxInt(valueWidth, utf8.RuneCountInString(domain.Formatted))
+		high = math.Max(high, domain.Value)
+	}
+	barWidth := maxInt(width-nameWidth-valueWidth-4, 10)
+
+	var out strings.Builder
+	out.WriteString(r.Title + "\n\n")
+	for _, domain := range r.Domains {
+		eighths := 0
+		if high > 0 {
+			eighths = int(math.Round(math.Max(domain.Value, 0) / high * float64(barWidth*8)))
+		}
+		bar := strings.Repeat("█", eighths/8)
+		if eighths%8 > 0 {
+			bar += string(termHorizontalBlocks[eighths%8])
+		}
+		out.WriteString(fmt.Sprintf(
+			"%-*s │%s %s\n",
+			nameWidth,
+			domain.Domain,
+			bar,
+			domain.Formatted,
+		))
+	}
+
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+// termHorizontalBlocks are the characters used to draw the end of a horizontal
+// bar, in eighths of a character
+var termHorizontalBlocks = []rune{' ', '▏', '▎', '▍', '▌', '▋', '▊', '▉', '█'}
@@ -38,6 +38,12 @@ main() {
     elif [ "${1:-}" == "stats" ]
     then
         stats "$@"
+    elif [ "${1:-}" == "snapshots" ]
+    then
+        snapshots "$@"
+    elif [ "${1:-}" == "status" ]
+    then
+        status "$@"
     else
         print_main_help
     fi
@@ -68,6 +74,8 @@ Available Commands:
   recover     Mount backed up versions of domains for recovery.
   fsd         Control the filesystem agent.
   stats       Make a chart for a backup metric.
+  snapshots   List snapshots of a group in a repo.
+  status      Report how long ago each domain was backed up.
 
 Options:
   -h --help     Show this screen.
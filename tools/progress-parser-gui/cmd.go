@@ -11,8 +11,7 @@
 package main
 
 import (
-	"bufio"
-	"encoding/json"
+	"io"
 	"os"
 
 	"github.com/nattvara/dfb/internal/gui/progress"
@@ -28,18 +27,24 @@ func main() {
 	report := progress.New(app)
 	report.LoadUI(app)
 
-	messages := make(chan restic.Message)
+	events := make(chan *restic.Event)
+	errors := make(chan error)
 
 	go func() {
-		scanner := bufio.NewScanner(os.Stdin)
-		for scanner.Scan() {
-			var msg restic.Message
-			json.Unmarshal(scanner.Bytes(), &msg)
-			msg.Body = scanner.Text()
-			messages <- msg
+		decoder := restic.NewDecoder(os.Stdin)
+		for {
+			event, err := decoder.Decode()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				errors <- err
+				continue
+			}
+			events <- event
 		}
 	}()
 
-	go report.MessageReceiver.ListenForMessages(messages)
+	go report.MessageReceiver.ListenForMessages(events, errors)
 	app.Run()
 }
@@ -1,9 +1,8 @@
 package main
 
 import (
-	"bufio"
-	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 
 	d "github.com/nattvara/dfb/internal/domains"
@@ -31,24 +30,32 @@ func main() {
 	tm.Flush()
 	var linesPrinted int
 
-	scanner := bufio.NewScanner(os.Stdin)
+	decoder := restic.NewDecoder(os.Stdin)
+	var failed []restic.ErrorMessage
 
-	for scanner.Scan() {
-		ClearPreviousLines(linesPrinted)
+	for {
+		event, err := decoder.Decode()
+		if err == io.EOF {
+			break
+		}
 
-		var msg restic.Message
-		json.Unmarshal(scanner.Bytes(), &msg)
+		if err != nil {
+			ClearPreviousLines(linesPrinted)
+			PrintDecodeError(err)
+			linesPrinted = 0
+			continue
+		}
 
-		switch msg.Type {
-		case "":
+		switch event.Type {
 		case "status":
-			var status restic.StatusMessage
-			json.Unmarshal(scanner.Bytes(), &status)
-			linesPrinted = PrintStatusMessage(status, domain)
+			ClearPreviousLines(linesPrinted)
+			linesPrinted = PrintStatusMessage(*event.Status, domain, len(failed))
 		case "summary":
-			var summary restic.SummaryMessage
-			json.Unmarshal(scanner.Bytes(), &summary)
-			linesPrinted = PrintSummaryMessage(summary, domain)
+			ClearPreviousLines(linesPrinted)
+			PrintFailedPaths(failed)
+			linesPrinted = PrintSummaryMessage(*event.Summary, domain)
+		case "error":
+			failed = append(failed, *event.Error)
 		}
 	}
 }
@@ -64,8 +71,9 @@ func ClearPreviousLines(number int) {
 	tm.Flush()
 }
 
-// PrintStatusMessage prints a status message with procent done, ETA and which files are currently being backed up
-func PrintStatusMessage(msg restic.StatusMessage, domain d.Domain) int {
+// PrintStatusMessage prints a status message with procent done, ETA, number of errors and which
+// files are currently being backed up
+func PrintStatusMessage(msg restic.StatusMessage, domain d.Domain, errorCount int) int {
 	var linesPrinted int
 
 	message := fmt.Sprintf("  backing up %s", domain.Name)
@@ -80,6 +88,11 @@ func PrintStatusMessage(msg restic.StatusMessage, domain d.Domain) int {
 	tm.Printf("\n")
 	linesPrinted++
 
+	if errorCount > 0 {
+		tm.Printf("  ⚠  %v errors\n", errorCount)
+		linesPrinted++
+	}
+
 	if len(msg.CurrentFiles) == 1 {
 		tm.Println(msg.CurrentFiles[0])
 		linesPrinted++
@@ -105,3 +118,21 @@ func PrintSummaryMessage(msg restic.SummaryMessage, domain d.Domain) int {
 	tm.Flush()
 	return linesPrinted
 }
+
+// PrintFailedPaths prints the paths restic failed to back up, these lines are not cleared
+func PrintFailedPaths(failed []restic.ErrorMessage) {
+	if len(failed) == 0 {
+		return
+	}
+	tm.Printf("  ⚠  %v errors while backing up\n", len(failed))
+	for _, msg := range failed {
+		tm.Printf("     %s: %s\n", msg.Item, msg.GetErrorString())
+	}
+	tm.Flush()
+}
+
+// PrintDecodeError prints an error for output from restic that could not be decoded
+func PrintDecodeError(err error) {
+	tm.Printf("  failed to read restic output, %s\n", err.Error())
+	tm.Flush()
+}
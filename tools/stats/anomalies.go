@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nattvara/dfb/internal/stats"
+
+	"github.com/spf13/cobra"
+)
+
+var anomaliesFormat string
+
+var anomaliesFrom string
+
+var anomaliesTo string
+
+var anomaliesWindow int
+
+var anomaliesDeviation float64
+
+var anomaliesCmd = &cobra.Command{
+	Use:   "anomalies [group|all] [repo|all] [domain]",
+	Short: "List snapshots that were far from the usual for their domain",
+	Long: `The anomalies command lists the snapshots that added much more or less data,
+took much longer or shorter time, or processed many more or fewer files than the
+snapshots of the same domain before them usually did. The baseline of a snapshot
+is the median of the previous --window snapshots of the domain, and a snapshot is
+an anomaly if it is more than --deviation deviations from it`,
+	Args: cobra.RangeArgs(2, 3),
+	Run: func(cmd *cobra.Command, args []string) {
+		groupName := wildcard(args[0], stats.AllGroups)
+		repoName := wildcard(args[1], stats.AllRepos)
+		domain := stats.AllDomains
+		if len(args) == 3 {
+			domain = args[2]
+		}
+
+		from, to, err := parseDateRange(anomaliesFrom, anomaliesTo)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if anomaliesWindow < 1 || anomaliesDeviation <= 0 {
+			fmt.Println("--window and --deviation must be greater than 0")
+			os.Exit(1)
+		}
+
+		db := stats.NewDB()
+		db.Load(groupName)
+
+		options := stats.AnomalyOptions{Window: anomaliesWindow, Deviation: anomaliesDeviation}
+		rows := []anomalyRow{}
+		for _, anomaly := range stats.DetectAnomalies(db, groupName, repoName, domain, options) {
+			if !from.IsZero() && anomaly.Date.Before(from) {
+				continue
+			}
+			if !to.IsZero() && !anomaly.Date.Before(to) {
+				continue
+			}
+			rows = append(rows, newAnomalyRow(anomaly))
+		}
+
+		switch anomaliesFormat {
+		case formatTable:
+			printAnomaliesTable(rows)
+		case stats.ExportFormatJSON:
+			printJSON(rows)
+		default:
+			fmt.Println("unknown format " + anomaliesFormat)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	anomaliesCmd.Flags().StringVarP(&anomaliesFormat, "format", "f", formatTable, "output format, table or json")
+	anomaliesCmd.Flags().StringVarP(&anomaliesFrom, "from", "", "", "only list snapshots taken on or after date (YYYY-MM-DD)")
+	anomaliesCmd.Flags().StringVarP(&anomaliesTo, "to", "", "", "only list snapshots taken on or before date (YYYY-MM-DD)")
+	anomaliesCmd.Flags().IntVarP(&anomaliesWindow, "window", "", stats.DefaultAnomalyWindow, "how many previous snapshots of a domain the baseline is computed from")
+	anomaliesCmd.Flags().Float64VarP(&anomaliesDeviation, "deviation", "", stats.DefaultAnomalyDeviation, "how many deviations from the baseline a value must be to be an anomaly")
+}
+
+// anomalyRow is an anomaly listed by the anomalies command
+type anomalyRow struct {
+	Time       time.Time `json:"time"`
+	Group      string    `json:"group"`
+	Repo       string    `json:"repo"`
+	Domain     string    `json:"domain"`
+	SnapshotID string    `json:"snapshot_id"`
+	Field      string    `json:"field"`
+	Value      float64   `json:"value"`
+	Baseline   float64   `json:"baseline"`
+	Deviation  float64   `json:"deviation"`
+
+	formatter stats.Formatter
+}
+
+// newAnomalyRow returns an anomalyRow for anomaly
+func newAnomalyRow(anomaly stats.Anomaly) anomalyRow {
+	return anomalyRow{
+		Time:       anomaly.Date,
+		Group:      anomaly.Group,
+		Repo:       anomaly.Repo,
+		Domain:     anomaly.Domain,
+		SnapshotID: anomaly.SnapshotID,
+		Field:      anomaly.Name,
+		Value:      anomaly.Value,
+		Baseline:   anomaly.Baseline,
+		Deviation:  anomaly.Deviation,
+		formatter:  anomaly.Formatter,
+	}
+}
+
+// printAnomaliesTable prints given rows as a table
+func printAnomaliesTable(rows []anomalyRow) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tGROUP\tREPO\tDOMAIN\tSNAPSHOT\tFIELD\tVALUE\tBASELINE\tDEVIATION")
+	for _, row := range rows {
+		id := row.SnapshotID
+		if len(id) > 8 {
+			id = id[:8]
+		}
+		fmt.Fprintf(
+			w,
+			"%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%+.1f\n",
+			row.Time.Local().Format("2006-01-02 15:04:05"),
+			row.Group,
+			row.Repo,
+			row.Domain,
+			id,
+			row.Field,
+			row.formatter.Format(row.Value),
+			row.formatter.Format(row.Baseline),
+			row.Deviation,
+		)
+	}
+	w.Flush()
+	fmt.Printf("\n%v anomalies\n", len(rows))
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/nattvara/dfb/internal/groups"
+	"github.com/nattvara/dfb/internal/restic"
+	"github.com/nattvara/dfb/internal/stats"
+
+	"github.com/spf13/cobra"
+)
+
+var backfillCmd = &cobra.Command{
+	Use:   "backfill [group] [repo]",
+	Short: "Rebuild stats history from the snapshots in a repo",
+	Long: `The backfill command regenerates the snapshot and domain stats of a group
+from the snapshots in an existing repo, by running restic stats for every
+snapshot tagged with one of the domains of the group. Snapshots that already
+have stats are skipped, so it is safe to run more than once. The password of
+the repo is read from stdin.
+
+Backfilled rows are marked with source backfill in the stats files. The data
+added and time taken by a backfilled snapshot is not known and is recorded as 0,
+the disk space used by the whole repo cannot be backfilled`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		group := groups.Load(args[0])
+		repoName := args[1]
+
+		repoPath, err := group.RepoPath(repoName)
+		if err != nil {
+			fmt.Println("unknown repo " + repoName)
+			os.Exit(1)
+		}
+
+		var domains []string
+		for _, domain := range group.Domains() {
+			domains = append(domains, domain.Name)
+		}
+
+		client := restic.NewClient(repoPath, &restic.ReaderPassword{Reader: os.Stdin})
+		backfilled, err := stats.Backfill(
+			client,
+			group.Name,
+			repoName,
+			domains,
+			func(current int, total int, domain string, snapshot restic.Snapshot) {
+				fmt.Printf("[%v/%v] backfilling %s %s\n", current, total, domain, snapshot.ShortID)
+			},
+		)
+		fmt.Printf("backfilled stats for %v snapshots\n", backfilled)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
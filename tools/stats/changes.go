@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/nattvara/dfb/internal/stats"
+
+	"github.com/spf13/cobra"
+)
+
+var changesCmd = &cobra.Command{
+	Use:   "changes [group] [snapshot]",
+	Short: "List the files that were new or modified in a snapshot",
+	Long: `The changes command lists the files and directories that were new or
+modified in a snapshot, as recorded during backup. The snapshot id may be
+shortened as long as it is unique`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		changes, err := stats.ReadChanges(args[0], args[1])
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		formatter := &stats.BytesFormatter{}
+		for _, change := range changes {
+			fmt.Printf("%-10s %12s  %s\n", change.Action, formatter.Format(float64(change.DataSize)), change.Item)
+		}
+	},
+}
+
+var recordChangesCmd = &cobra.Command{
+	Use:   "record-changes [group]",
+	Short: "Record the files that were new or modified in a snapshot",
+	Long: `The record-changes command reads the json output of restic backup --verbose
+on stdin and stores the files that were new or modified, once the backup is done.
+It is used by the backup command, which appends errors to
+~/.dfb/[group]/stats/record-changes.log`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if _, err := stats.RecordChanges(os.Stdin, args[0]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	},
+}
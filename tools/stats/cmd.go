@@ -1,25 +1,49 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"sort"
+	"strings"
+	"time"
 
+	"github.com/nattvara/dfb/internal/groups"
 	"github.com/nattvara/dfb/internal/stats"
 
+	tm "github.com/buger/goterm"
 	"github.com/spf13/cobra"
 )
 
-var domainName string
+var domainNames []string
+
+var repoNames []string
+
+var compareDomains string
 
 var timeUnit string
 
 var timeLength int
 
+var fromDate string
+
+var toDate string
+
 var aggregatorName string
 
+var gapFill string
+
+var quota string
+
+var forecastLength int
+
+var anomalyDeviation float64
+
 var outputPath string
 
+var outputFormat string
+
 var shouldListMetrics bool
 
 var shouldListTimeUnits bool
@@ -27,44 +51,85 @@ var shouldListTimeUnits bool
 var shouldListAggregators bool
 
 var cmd = &cobra.Command{
-	Use:   "stats [group] [repo] [metric]",
+	Use:   "stats [group|all] [repo|all] [metric]",
 	Short: "Make a chart for a backup metric",
 	Long:  "The stats command allows a user to view metrics about the backed up data",
-	Args:  cobra.MinimumNArgs(3),
+	Args: func(cmd *cobra.Command, args []string) error {
+		if shouldList() {
+			return nil
+		}
+		return cobra.MinimumNArgs(3)(cmd, args)
+	},
 	Run: func(cmd *cobra.Command, args []string) {
-		groupName := args[0]
-		repoName := args[1]
+		if shouldList() {
+			list()
+			return
+		}
+
+		groupName := wildcard(args[0], stats.AllGroups)
+		repoName := wildcard(args[1], stats.AllRepos)
 		metricName := args[2]
 
-		var metric stats.Metric
 		var aggregator stats.Aggregator
 		var err error
 
-		if domainName == "" {
-			domainName = stats.AllDomains
+		repos := []string{repoName}
+		for _, name := range repoNames {
+			repos = append(repos, wildcard(name, stats.AllRepos))
+		}
+		repos = uniqueStrings(repos)
+		domains, err := comparedDomains(groupName)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		if err := stats.ValidateGapFill(gapFill); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		from, to, err := parseDateRange(fromDate, toDate)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if !from.IsZero() && to.IsZero() {
+			to = time.Now()
+		}
+		if !from.IsZero() && !from.Before(to) {
+			fmt.Println("--from must be before --to")
+			os.Exit(1)
 		}
 
 		db := stats.NewDB()
 		db.Load(groupName)
 
-		if metric, err = stats.NewMetric(
+		comparison, err := stats.NewComparison(
 			metricName,
-			repoName,
+			repos,
 			groupName,
-			domainName,
+			domains,
 			timeUnit,
 			aggregatorName,
-		); err != nil {
+		)
+		if err != nil {
 			fmt.Println(err)
 			os.Exit(1)
 		}
 
-		metric.FetchDataFromDB(db, timeUnit, timeLength)
+		comparison.SetGapFill(gapFill)
+		comparison.SetDateRange(from, to)
+		if err := setForecast(comparison); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		comparison.FetchDataFromDB(db, timeUnit, timeLength)
 
 		if aggregatorName != "" {
-			aggregator, err = stats.NewAggregator(aggregatorName)
+			aggregator, err = newAggregatorForMetric(aggregatorName, comparison.Series[0].Metric, metricName)
 		} else {
-			aggregator = metric.GetDefaultAggregator()
+			aggregator = comparison.Series[0].Metric.GetDefaultAggregator()
 		}
 
 		if err != nil {
@@ -72,43 +137,315 @@ var cmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		chart := stats.LineChart{
-			Metric:     metric,
-			Aggregator: aggregator,
+		if len(comparison.Series) == 1 {
+			err = writeMetric(cmd, db, comparison.Series[0].Metric, aggregator)
+		} else {
+			err = writeComparison(cmd, db, comparison, aggregator)
 		}
 
-		err = chart.WriteToFile(outputPath)
 		if err != nil {
 			fmt.Println(err)
 			os.Exit(1)
 		}
+
+		if outputFormat == formatPNG || outputFormat == formatTerm || outputFormat == formatTable {
+			printForecasts(comparison, aggregator)
+		}
 	},
 }
 
-func main() {
-	cmd.Flags().StringVarP(&domainName, "domain", "d", "", "which domain to use for metric, not availiable for all metrics, optional/required for some metrics")
+// newAggregatorForMetric returns the aggregator with given name, or an error if
+// it does not make sense for metric
+func newAggregatorForMetric(name string, metric stats.Metric, metricName string) (stats.Aggregator, error) {
+	aggregator, err := stats.NewAggregator(name)
+	if err != nil {
+		return nil, err
+	}
+	if !stats.SupportsAggregator(metric, name) {
+		return nil, fmt.Errorf(
+			"aggregator %s does not make sense for metric %s, availible aggregators are %s",
+			name,
+			metricName,
+			strings.Join(metric.GetAggregators(), ", "),
+		)
+	}
+	return aggregator, nil
+}
+
+// setForecast sets the --forecast-length and --quota flags on the series of
+// comparison, the flags are only accepted for forecast metrics
+func setForecast(comparison *stats.Comparison) error {
+	var bytes float64
+	if quota != "" {
+		var err error
+		if bytes, err = stats.ParseBytes(quota); err != nil {
+			return err
+		}
+	}
+
+	for _, series := range comparison.Series {
+		forecast, ok := series.Metric.(stats.ForecastMetric)
+		if !ok {
+			if quota != "" || forecastLength != 0 {
+				return errors.New("--quota and --forecast-length are only availible for forecast metrics")
+			}
+			continue
+		}
+		forecast.SetForecast(forecastLength, bytes)
+	}
+	return nil
+}
+
+// printForecasts prints the estimate of every forecast metric of comparison
+func printForecasts(comparison *stats.Comparison, aggregator stats.Aggregator) {
+	for _, series := range comparison.Series {
+		forecast, ok := series.Metric.(stats.ForecastMetric)
+		if !ok {
+			continue
+		}
+		summary := forecast.GetForecast(aggregator).Summary(forecast.GetFormatter())
+		if len(comparison.Series) > 1 {
+			summary = series.Name + ": " + summary
+		}
+		fmt.Println(summary)
+	}
+}
+
+// writeMetric writes metric in the format of the --format flag, the anomalies
+// of metrics of snapshots in db are marked on png charts
+func writeMetric(cmd *cobra.Command, db *stats.DB, metric stats.Metric, aggregator stats.Aggregator) error {
+	switch outputFormat {
+	case formatPNG:
+		return newChart(db, metric, aggregator, anomalyDeviation).WriteToFile(outputPath)
+	case formatTerm:
+		chart := stats.TermChart{
+			Metric:     metric,
+			Aggregator: aggregator,
+			Width:      tm.Width(),
+		}
+		return chart.Write(os.Stdout)
+	case formatTable:
+		table := stats.TermTable{
+			Metric:     metric,
+			Aggregator: aggregator,
+		}
+		return table.Write(os.Stdout)
+	}
+
+	export := stats.Export{
+		Metric:     metric,
+		Aggregator: aggregator,
+	}
+	return writeExport(cmd, func(w io.Writer) error {
+		return export.Write(w, outputFormat)
+	})
+}
+
+// newChart returns the chart of metric that fits it best, the anomalies of
+// metrics of snapshots in db are marked if deviation is greater than 0
+func newChart(db *stats.DB, metric stats.Metric, aggregator stats.Aggregator, deviation float64) stats.Chart {
+	if forecast, ok := metric.(stats.ForecastMetric); ok {
+		return &stats.ForecastChart{
+			Metric:     forecast,
+			Aggregator: aggregator,
+		}
+	}
+	if stacked, ok := metric.(stats.StackedMetric); ok {
+		return &stats.StackedChart{
+			Metric:     stacked,
+			Aggregator: aggregator,
+		}
+	}
+	chart := &stats.LineChart{
+		Metric:     metric,
+		Aggregator: aggregator,
+	}
+	if m, ok := metric.(stats.AnomalyMetric); ok && deviation > 0 {
+		options := stats.DefaultAnomalyOptions()
+		options.Deviation = deviation
+		chart.Anomalies = stats.GetAnomaliesOf(db, m, options)
+	}
+	return chart
+}
+
+// writeComparison writes comparison in the format of the --format flag, in
+// the terminal formats the series are written one after another
+func writeComparison(cmd *cobra.Command, db *stats.DB, comparison *stats.Comparison, aggregator stats.Aggregator) error {
+	if outputFormat == formatPNG {
+		chart := stats.ComparisonChart{
+			Comparison: comparison,
+			Aggregator: aggregator,
+		}
+		return chart.WriteToFile(outputPath)
+	}
+
+	if outputFormat == formatTerm || outputFormat == formatTable {
+		for i, series := range comparison.Series {
+			if i > 0 {
+				fmt.Println()
+			}
+			if err := writeMetric(cmd, db, series.Metric, aggregator); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var exports []*stats.Export
+	for _, series := range comparison.Series {
+		exports = append(exports, &stats.Export{
+			Metric:     series.Metric,
+			Aggregator: aggregator,
+		})
+	}
+	return writeExport(cmd, func(w io.Writer) error {
+		return stats.WriteExports(w, outputFormat, exports)
+	})
+}
+
+// comparedDomains returns the domains given with the --domain and
+// --compare-domains flags, or AllDomains if none was given
+func comparedDomains(groupName string) ([]string, error) {
+	domains := uniqueStrings(domainNames)
+
+	switch compareDomains {
+	case "":
+	case "all":
+		domains = groupDomains(groupName)
+	default:
+		return domains, errors.New("unknown value for --compare-domains " + compareDomains + ", expected all")
+	}
+
+	if len(domains) == 0 {
+		domains = append(domains, stats.AllDomains)
+	}
+	return domains, nil
+}
+
+// groupDomains returns the names of the domains of group with given name, or of
+// every group if given stats.AllGroups
+func groupDomains(groupName string) []string {
+	var loaded []groups.Group
+	if groupName == stats.AllGroups {
+		loaded = groups.FetchGroups()
+	} else {
+		loaded = append(loaded, groups.Load(groupName))
+	}
+
+	var domains []string
+	for _, group := range loaded {
+		for _, domain := range group.Domains() {
+			domains = append(domains, domain.Name)
+		}
+	}
+	return uniqueStrings(domains)
+}
+
+// wildcard returns given wildcard if name is all, the name of a group or repo
+// that means all groups or repos, otherwise name
+func wildcard(name string, all string) string {
+	if name == "all" {
+		return all
+	}
+	return name
+}
+
+// uniqueStrings returns values without duplicates, in the order they were first given
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool)
+	unique := []string{}
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
+
+const (
+	// formatPNG is the default output format, a png image of a chart
+	formatPNG = "png"
+
+	// formatTerm is the output format for a chart drawn in the terminal
+	formatTerm = "term"
+
+	// formatTable is the output format for a table of the values printed to the terminal
+	formatTable = "table"
+)
+
+// writeExport calls write with stdout, or with the file at the path of the
+// --output flag if it was given
+func writeExport(cmd *cobra.Command, write func(w io.Writer) error) error {
+	if !cmd.Flags().Changed("output") {
+		return write(os.Stdout)
+	}
+
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return errors.New("failed to open file. " + err.Error())
+	}
+	defer file.Close()
+	return write(file)
+}
+
+func init() {
+	cmd.Flags().StringSliceVarP(&domainNames, "domain", "d", []string{}, "which domain to use for metric, not availiable for all metrics, optional/required for some metrics. Repeat to compare domains")
+	cmd.Flags().StringSliceVarP(&repoNames, "repo", "r", []string{}, "another repo to compare the metric with, or all for the total of all repos, can be repeated")
+	cmd.Flags().StringVarP(&compareDomains, "compare-domains", "", "", "set to all to compare all domains of the group")
 	cmd.Flags().StringVarP(&timeUnit, "time-unit", "u", stats.TimeUnitDays, "time unit to use for metric")
 	cmd.Flags().IntVarP(&timeLength, "time-length", "l", 7, "how many time-units of history should be included")
+	cmd.Flags().StringVarP(&fromDate, "from", "", "", "include the time units from date (YYYY-MM-DD) instead of the last --time-length time units")
+	cmd.Flags().StringVarP(&toDate, "to", "", "", "include the time units until and including date (YYYY-MM-DD), defaults to today")
 	cmd.Flags().StringVarP(&aggregatorName, "aggregator", "a", "", "aggregation method to use for a metric")
-	cmd.Flags().StringVarP(&outputPath, "output", "o", "/tmp/dfb-metric.png", "output path for png image of metric")
+	cmd.Flags().StringVarP(&gapFill, "gap-fill", "", stats.GapFillCarry, "how to fill dates without values of gauge metrics, "+strings.Join(stats.GapFills, ", "))
+	cmd.Flags().StringVarP(&quota, "quota", "", "", "size of the quota of a repo, eg. 500GiB, to estimate when a forecast metric reaches it")
+	cmd.Flags().IntVarP(&forecastLength, "forecast-length", "", 0, "how many time-units a forecast metric is projected, defaults to --time-length")
+	cmd.Flags().Float64VarP(&anomalyDeviation, "anomaly-deviation", "", stats.DefaultAnomalyDeviation, "how many deviations from the baseline a snapshot must be to be marked as an anomaly on png charts, 0 to not mark anomalies")
+	cmd.Flags().StringVarP(&outputPath, "output", "o", "/tmp/dfb-metric.png", "output path for png image of metric, exports are written to stdout unless given")
+	cmd.Flags().StringVarP(&outputFormat, "format", "f", formatPNG, "output format, png, term, table, "+strings.Join(stats.ExportFormats, ", "))
 	cmd.Flags().BoolVarP(&shouldListMetrics, "list-metrics", "", false, "list availiable metrics")
 	cmd.Flags().BoolVarP(&shouldListTimeUnits, "list-time-units", "", false, "list availiable time units")
 	cmd.Flags().BoolVarP(&shouldListAggregators, "list-aggregators", "", false, "list availiable aggregators")
-	cmd.Execute()
+	cmd.AddCommand(migrateCmd)
+	cmd.AddCommand(doctorCmd)
+	cmd.AddCommand(changesCmd)
+	cmd.AddCommand(recordChangesCmd)
+	cmd.AddCommand(snapshotsCmd)
+	cmd.AddCommand(backfillCmd)
+	cmd.AddCommand(topCmd)
+	cmd.AddCommand(pricingCmd)
+	cmd.AddCommand(statusCmd)
+	cmd.AddCommand(anomaliesCmd)
+	cmd.AddCommand(exportCmd)
+	cmd.AddCommand(serveCmd)
+	cmd.AddCommand(reportCmd)
+}
 
-	if shouldListMetrics {
-		listMetrics()
-		return
+func main() {
+	if c, err := cmd.ExecuteC(); err != nil {
+		if c == statusCmd {
+			os.Exit(statusExitUnknown)
+		}
+		os.Exit(1)
 	}
+}
 
-	if shouldListTimeUnits {
-		listTimeUnits()
-		return
-	}
+// shouldList returns whether any of the --list flags is given, the stats
+// command then takes no args
+func shouldList() bool {
+	return shouldListMetrics || shouldListTimeUnits || shouldListAggregators
+}
 
-	if shouldListAggregators {
+// list lists what the first of the --list flags given asks for
+func list() {
+	switch {
+	case shouldListMetrics:
+		listMetrics()
+	case shouldListTimeUnits:
+		listTimeUnits()
+	case shouldListAggregators:
 		listAggregators()
-		return
 	}
 }
 
@@ -122,7 +459,8 @@ func listMetrics() {
 	sort.Strings(metrics)
 	fmt.Println("availible metrics are:")
 	for _, name := range metrics {
-		fmt.Printf("  %s\n", name)
+		metric, _ := stats.NewMetric(name, "", "", stats.AllDomains, stats.TimeUnitDays, "")
+		fmt.Printf("  %s (%s)\n", name, metric.GetKind())
 	}
 }
 
@@ -134,9 +472,35 @@ func listTimeUnits() {
 }
 
 func listAggregators() {
-	fmt.Println("availible aggregators are:")
+	var aggregators []string
+
 	for name := range stats.Aggregators {
+		aggregators = append(aggregators, name)
+	}
+
+	sort.Strings(aggregators)
+	fmt.Println("availible aggregators are:")
+	for _, name := range aggregators {
 		fmt.Printf("  %s\n", name)
 	}
-	fmt.Println("\nnote: not all of these aggregators makes sense for all metrics")
+	fmt.Println("\nany percentile can be used as pN, eg. p90, and a moving average over any number of buckets as moving-average-N")
+
+	var metrics []string
+	for name := range stats.Metrics {
+		metrics = append(metrics, name)
+	}
+
+	sort.Strings(metrics)
+	fmt.Println("\naggregators availible for each metric, the default first:")
+	for _, name := range metrics {
+		metric, _ := stats.NewMetric(name, "", "", stats.AllDomains, stats.TimeUnitDays, "")
+		defaultName := stats.GetAggregatorName(metric.GetDefaultAggregator())
+		names := []string{defaultName}
+		for _, aggregator := range metric.GetAggregators() {
+			if aggregator != defaultName {
+				names = append(names, aggregator)
+			}
+		}
+		fmt.Printf("  %s: %s\n", name, strings.Join(names, ", "))
+	}
 }
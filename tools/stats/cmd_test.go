@@ -0,0 +1,61 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+// executeCapturingStdout executes the stats command with given args and returns
+// what it printed to stdout
+func executeCapturingStdout(t *testing.T, args ...string) (string, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	cmd.SetArgs(args)
+	_, err = cmd.ExecuteC()
+	w.Close()
+
+	out, readErr := ioutil.ReadAll(r)
+	if readErr != nil {
+		t.Fatal(readErr)
+	}
+	return string(out), err
+}
+
+func TestListFlagsTakeNoArgs(t *testing.T) {
+	tests := []struct {
+		flag string
+		set  *bool
+		want []string
+	}{
+		{"--list-metrics", &shouldListMetrics, []string{"availible metrics are:", "domain-disk-space (gauge)"}},
+		{"--list-time-units", &shouldListTimeUnits, []string{"availible time units are:", "quarters"}},
+		{"--list-aggregators", &shouldListAggregators, []string{"availible aggregators are:", "moving-average-N"}},
+	}
+	for _, tt := range tests {
+		out, err := executeCapturingStdout(t, tt.flag)
+		*tt.set = false
+		if err != nil {
+			t.Errorf("%s: got error %v", tt.flag, err)
+			continue
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(out, want) {
+				t.Errorf("%s: got output %q, want it to contain %q", tt.flag, out, want)
+			}
+		}
+	}
+}
+
+func TestStatsRequiresArgsWithoutListFlags(t *testing.T) {
+	if _, err := executeCapturingStdout(t); err == nil {
+		t.Error("got no error without args, want one")
+	}
+}
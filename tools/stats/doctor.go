@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/nattvara/dfb/internal/stats"
+
+	"github.com/spf13/cobra"
+)
+
+var shouldQuarantine bool
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor [group]",
+	Short: "Scan the stats files of a group for problems",
+	Long: `The doctor command scans every stats file of a group and reports malformed
+rows, numbers and dates that cannot be parsed and duplicate snapshot ids.
+The files are only modified if the --quarantine flag is used, which moves
+the rows with problems to a .rejected file next to the stats file`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		problems, err := stats.Diagnose(stats.StatsDir(args[0]))
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		for _, problem := range problems {
+			fmt.Println(problem)
+		}
+
+		if len(problems) == 0 {
+			fmt.Println("no problems found")
+			return
+		}
+
+		if !shouldQuarantine {
+			fmt.Printf("\nfound %v problems, run with --quarantine to move the rows to .rejected files\n", len(problems))
+			os.Exit(1)
+		}
+
+		moved, err := stats.Quarantine(problems)
+		fmt.Printf("\nfound %v problems, moved %v rows to .rejected files\n", len(problems), moved)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	doctorCmd.Flags().BoolVarP(&shouldQuarantine, "quarantine", "", false, "move rows with problems to a .rejected file next to the stats file")
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/nattvara/dfb/internal/stats"
+
+	"github.com/spf13/cobra"
+)
+
+var exportPrometheus bool
+
+var exportOutput string
+
+var exportListen string
+
+var exportCmd = &cobra.Command{
+	Use:   "export [group|all]",
+	Short: "Export the latest stats for a monitoring system",
+	Long: `The export command exports the latest stats of a group, or of all groups if
+no group is given, such as the time of the last snapshot and the size of each
+domain. With --prometheus the stats are written in the Prometheus text format,
+to a file for the textfile collector of node_exporter with --output, or served
+over http on /metrics with --listen, where the stats are reloaded on every scrape`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		groupName := stats.AllGroups
+		if len(args) == 1 {
+			groupName = wildcard(args[0], stats.AllGroups)
+		}
+
+		if !exportPrometheus {
+			fmt.Println("an export format is required, eg. --prometheus")
+			os.Exit(1)
+		}
+
+		if exportListen != "" {
+			http.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+				var buffer bytes.Buffer
+				if err := stats.WritePrometheus(&buffer, loadDB(groupName)); err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+				buffer.WriteTo(w)
+			})
+			fmt.Printf("serving metrics on http://%s/metrics\n", exportListen)
+			if err := http.ListenAndServe(exportListen, nil); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		var buffer bytes.Buffer
+		if err := stats.WritePrometheus(&buffer, loadDB(groupName)); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if exportOutput == "" {
+			buffer.WriteTo(os.Stdout)
+			return
+		}
+		if err := writeFileAtomically(exportOutput, buffer.Bytes()); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	exportCmd.Flags().BoolVarP(&exportPrometheus, "prometheus", "", false, "export in the Prometheus text format")
+	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "path of the file to write, eg. a .prom file in the directory of the textfile collector, stdout if not given")
+	exportCmd.Flags().StringVarP(&exportListen, "listen", "", "", "address to serve the metrics on instead, eg. localhost:9842")
+}
+
+// loadDB returns a db loaded with the stats of group with given name
+func loadDB(groupName string) *stats.DB {
+	db := stats.NewDB()
+	db.Load(groupName)
+	return db
+}
+
+// writeFileAtomically writes data to file at given path by writing it to a
+// temporary file next to it that is then renamed, so that readers of the file
+// never see it half written
+func writeFileAtomically(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path))
+	if err != nil {
+		return errors.New("failed to create file. " + err.Error())
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return errors.New("failed to write file. " + err.Error())
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.New("failed to write file. " + err.Error())
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return errors.New("failed to write file. " + err.Error())
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return errors.New("failed to write file. " + err.Error())
+	}
+	return nil
+}
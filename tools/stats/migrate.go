@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/nattvara/dfb/internal/stats"
+
+	"github.com/spf13/cobra"
+)
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate [group]",
+	Short: "Migrate the stats files of a group to the current schema",
+	Long: `The migrate command rewrites stats files written by older versions of dfb
+to the current schema, which has a version line and a header row. Missing
+stats files are created. The original content of rewritten files is kept
+in a .vN.bak file next to them, N being the old schema version`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		migrated, err := stats.Migrate(args[0])
+		for _, path := range migrated {
+			fmt.Printf("migrated %s\n", path)
+		}
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/nattvara/dfb/internal/groups"
+	"github.com/nattvara/dfb/internal/paths"
+	"github.com/nattvara/dfb/internal/stats"
+
+	"github.com/spf13/cobra"
+)
+
+var pricingCurrency string
+
+var pricingTiers []string
+
+var pricingCmd = &cobra.Command{
+	Use:   "pricing [group] [repo]",
+	Short: "Show or set the pricing of a repo",
+	Long: `The pricing command sets the price per GiB-month of storing a repo, used by
+the repo-cost metric. A tier is a size and a price, such as 50TiB:0.023, the
+first tier prices the first bytes stored and so on. A tier without a size, such
+as 0.022, prices all remaining bytes. Without flags the current pricing is shown`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		group := groups.Load(args[0])
+		repoName := args[1]
+
+		if _, err := group.RepoPath(repoName); err != nil {
+			fmt.Println("unknown repo " + repoName)
+			os.Exit(1)
+		}
+
+		if !cmd.Flags().Changed("currency") && !cmd.Flags().Changed("tier") {
+			pricing, err := stats.LoadPricing(group.Name, repoName)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			fmt.Print(pricing.String())
+			return
+		}
+
+		pricing := &stats.Pricing{}
+		if paths.Exists(stats.PricingPath(group.Name, repoName)) {
+			current, err := stats.LoadPricing(group.Name, repoName)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			pricing = current
+		}
+		if cmd.Flags().Changed("currency") {
+			pricing.Currency = pricingCurrency
+		}
+		if len(pricingTiers) > 0 {
+			pricing.Tiers = nil
+		}
+		for _, tier := range pricingTiers {
+			parsed, err := stats.ParsePricingTier(tier)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			pricing.Tiers = append(pricing.Tiers, parsed)
+		}
+
+		if err := pricing.Save(group.Name, repoName); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Print(pricing.String())
+	},
+}
+
+func init() {
+	pricingCmd.Flags().StringVarP(&pricingCurrency, "currency", "", "", "currency of the prices, eg. USD")
+	pricingCmd.Flags().StringSliceVarP(&pricingTiers, "tier", "t", []string{}, "a price per GiB-month, optionally for a size only, eg. 50TiB:0.023. Repeat for more tiers")
+}
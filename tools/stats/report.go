@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nattvara/dfb/internal/groups"
+	"github.com/nattvara/dfb/internal/stats"
+
+	"github.com/spf13/cobra"
+)
+
+// reportMovers is the number of domains listed in each ranking of top movers
+const reportMovers = 5
+
+var reportPeriodName string
+
+var reportOut string
+
+// reportPeriod is a period a report can cover, ending today, and the time unit
+// its charts use
+type reportPeriod struct {
+	timeUnit string
+	years    int
+	months   int
+	days     int
+}
+
+// reportPeriods are the periods a report can cover
+var reportPeriods = map[string]reportPeriod{
+	"week":    {timeUnit: stats.TimeUnitDays, days: 7},
+	"month":   {timeUnit: stats.TimeUnitDays, months: 1},
+	"quarter": {timeUnit: stats.TimeUnitWeeks, months: 3},
+	"year":    {timeUnit: stats.TimeUnitMonths, years: 1},
+}
+
+var reportCmd = &cobra.Command{
+	Use:   "report [group]",
+	Short: "Write a html report of the backups of a group",
+	Long: `The report command writes a single html file with a chart of every metric for
+the last --period, a summary of each domain in each repo with the snapshots
+taken and data added in the period and its current size, and the domains that
+grew and added the most data. The charts are embedded in the file, so it can be
+shared and opened without dfb`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		group := groups.Load(args[0])
+		if _, err := os.Stat(group.Path); err != nil {
+			fmt.Println("no group named " + args[0])
+			os.Exit(1)
+		}
+
+		period, ok := reportPeriods[reportPeriodName]
+		if !ok {
+			fmt.Println("unknown period " + reportPeriodName + ", availible periods are week, month, quarter, year")
+			os.Exit(1)
+		}
+
+		now := time.Now()
+		since := now.AddDate(-period.years, -period.months, -period.days)
+		since = time.Date(since.Year(), since.Month(), since.Day(), 0, 0, 0, 0, time.Local)
+
+		db := loadDB(group.Name)
+		report := htmlReport{
+			Group:     group.Name,
+			Period:    reportPeriodName,
+			Since:     since,
+			Generated: now,
+			Domains:   stats.SummarizeDomains(db, group.Name, since),
+		}
+
+		domains := groupDomains(group.Name)
+		for _, mover := range []struct{ metric, aggregator string }{
+			{"domain-disk-space", "delta"},
+			{"domain-data-added", ""},
+		} {
+			ranking, err := stats.NewRanking(db, mover.metric, stats.AllRepos, group.Name, domains, since, mover.aggregator, reportMovers)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			report.Movers = append(report.Movers, ranking)
+		}
+
+		var names []string
+		for name := range stats.Metrics {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			chart := reportChart{Metric: name}
+			svg, err := renderReportChart(db, name, group.Repos(), group.Name, period.timeUnit, since, now)
+			if err != nil {
+				chart.Error = err.Error()
+			} else {
+				chart.SVG = template.HTML(svg)
+			}
+			report.Charts = append(report.Charts, chart)
+		}
+
+		file, err := os.Create(reportOut)
+		if err != nil {
+			fmt.Println("failed to open file. " + err.Error())
+			os.Exit(1)
+		}
+		defer file.Close()
+		if err := reportTemplate.Execute(file, report); err != nil {
+			fmt.Println("failed to write report. " + err.Error())
+			os.Exit(1)
+		}
+		fmt.Println("report written to " + reportOut)
+	},
+}
+
+func init() {
+	reportCmd.Flags().StringVarP(&reportPeriodName, "period", "p", "month", "period the report covers, ending today, week, month, quarter or year")
+	reportCmd.Flags().StringVarP(&reportOut, "out", "o", "report.html", "path of the html file to write")
+}
+
+// renderReportChart renders the chart of metric with given name for the
+// time units from since until now as svg. Stacked metrics are drawn for all
+// repos, as a stack per repo cannot be compared, other metrics with a series
+// for each of given repos
+func renderReportChart(db *stats.DB, name string, repos []string, group string, timeUnit string, since time.Time, now time.Time) (string, error) {
+	if _, ok := stats.Metrics[name].(stats.StackedMetric); ok || len(repos) == 0 {
+		repos = []string{stats.AllRepos}
+	}
+
+	comparison, err := stats.NewComparison(name, repos, group, []string{stats.AllDomains}, timeUnit, "")
+	if err != nil {
+		return "", err
+	}
+	comparison.SetGapFill(stats.GapFillCarry)
+	comparison.SetDateRange(since, now)
+	comparison.FetchDataFromDB(db, timeUnit, 0)
+
+	aggregator := comparison.Series[0].Metric.GetDefaultAggregator()
+	var chart stats.Chart = &stats.ComparisonChart{
+		Comparison: comparison,
+		Aggregator: aggregator,
+	}
+	if len(comparison.Series) == 1 {
+		chart = newChart(db, comparison.Series[0].Metric, aggregator, stats.DefaultAnomalyDeviation)
+	}
+
+	var buffer bytes.Buffer
+	if err := chart.Write(&buffer, stats.ChartFormatSVG); err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
+
+// htmlReport is the data the report template is executed with
+type htmlReport struct {
+	Group     string
+	Period    string
+	Since     time.Time
+	Generated time.Time
+	Domains   []stats.DomainSummary
+	Movers    []*stats.Ranking
+	Charts    []reportChart
+}
+
+// reportChart is the chart of a metric in a report, or the reason it could
+// not be drawn
+type reportChart struct {
+	Metric string
+	SVG    template.HTML
+	Error  string
+}
+
+// reportTemplate is the html of a report, styled like the charts
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"bytes": (&stats.BytesFormatter{}).Format,
+	"date": func(date time.Time) string {
+		if date.IsZero() {
+			return "never"
+		}
+		return date.Local().Format("2006-01-02 15:04")
+	},
+	"title": strings.Title,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>dfb report for group {{.Group}}</title>
+<style>
+body { background: #424242; color: #fff; font-family: -apple-system, Helvetica, sans-serif; margin: 24px auto; max-width: 1200px; }
+table { border-collapse: collapse; margin-bottom: 24px; }
+th, td { text-align: left; padding: 4px 16px 4px 0; }
+td.value { text-align: right; }
+svg { width: 100%; height: auto; }
+.note { color: #aaa; }
+</style>
+</head>
+<body>
+<h1>Backups of group {{.Group}}</h1>
+<p class="note">{{title .Period}} from {{.Since.Format "2006-01-02"}} until {{.Generated.Format "2006-01-02"}}, generated {{date .Generated}}</p>
+
+<h2>Domains</h2>
+<table>
+<tr><th>Repo</th><th>Domain</th><th>Snapshots</th><th>Data added</th><th>Size</th><th>Restore size</th><th>Last backup</th></tr>
+{{range .Domains}}<tr><td>{{.Repo}}</td><td>{{.Domain}}</td><td class="value">{{.Snapshots}}</td><td class="value">{{bytes .DataAdded}}</td><td class="value">{{bytes .Size}}</td><td class="value">{{bytes .RestoreSize}}</td><td>{{date .LastSnapshot}}</td></tr>
+{{end}}</table>
+
+<h2>Top movers</h2>
+{{range .Movers}}<h3>{{.Title}}</h3>
+<table>
+{{range $i, $domain := .Domains}}<tr><td>{{$domain.Domain}}</td><td class="value">{{$domain.Formatted}}</td></tr>
+{{end}}</table>
+{{end}}
+<h2>Metrics</h2>
+{{range .Charts}}<h3>{{.Metric}}</h3>
+{{if .Error}}<p class="note">{{.Error}}</p>{{else}}{{.SVG}}{{end}}
+{{end}}</body>
+</html>
+`))
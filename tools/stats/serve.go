@@ -0,0 +1,355 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nattvara/dfb/internal/groups"
+	"github.com/nattvara/dfb/internal/stats"
+
+	"github.com/spf13/cobra"
+)
+
+// serveReloadInterval is how often the stats files are checked for changes
+const serveReloadInterval = 2 * time.Second
+
+var serveListen string
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve a dashboard of the stats of all groups on localhost",
+	Long: `The serve command loads the stats of all groups once and serves a dashboard
+where a chart of any metric can be drawn for any group, repo, domain, aggregator
+and time range. The stats are reloaded when the stats files change, and open
+dashboards are refreshed`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		d := &dashboard{}
+		d.reload()
+		go d.watch()
+
+		http.HandleFunc("/", d.handleIndex)
+		http.HandleFunc("/chart", d.handleChart)
+		http.HandleFunc("/version", d.handleVersion)
+
+		fmt.Printf("serving dashboard on http://%s\n", serveListen)
+		if err := http.ListenAndServe(serveListen, nil); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	serveCmd.Flags().StringVarP(&serveListen, "listen", "", "localhost:8642", "address to serve the dashboard on")
+}
+
+// dashboard is the state of the serve command, the db is replaced when the
+// stats files change and version is increased
+type dashboard struct {
+	mutex       sync.RWMutex
+	db          *stats.DB
+	version     int
+	fingerprint string
+}
+
+// reload loads the stats of all groups if the stats files changed since they
+// were last loaded
+func (d *dashboard) reload() {
+	fingerprint := statsFingerprint()
+	d.mutex.RLock()
+	changed := fingerprint != d.fingerprint
+	d.mutex.RUnlock()
+	if !changed {
+		return
+	}
+
+	db := loadDB(stats.AllGroups)
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.db = db
+	d.fingerprint = fingerprint
+	d.version++
+}
+
+// watch reloads the stats every serveReloadInterval, forever
+func (d *dashboard) watch() {
+	for range time.Tick(serveReloadInterval) {
+		d.reload()
+	}
+}
+
+// current returns the db and version of the stats currently loaded
+func (d *dashboard) current() (*stats.DB, int) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return d.db, d.version
+}
+
+// statsFingerprint returns a string that changes whenever a stats file of any
+// group is added, removed or modified
+func statsFingerprint() string {
+	var fingerprint strings.Builder
+	for _, group := range groups.FetchGroups() {
+		files, err := ioutil.ReadDir(stats.StatsDir(group.Name))
+		if err != nil {
+			continue
+		}
+		for _, file := range files {
+			fmt.Fprintf(&fingerprint, "%s/%s %v %v\n", group.Name, file.Name(), file.ModTime().UnixNano(), file.Size())
+		}
+	}
+	return fingerprint.String()
+}
+
+// dashboardQuery is the chart requested from the dashboard
+type dashboardQuery struct {
+	Group      string
+	Repo       string
+	Metric     string
+	Domain     string
+	Aggregator string
+	TimeUnit   string
+	TimeLength int
+	From       string
+	To         string
+	Format     string
+}
+
+// parseDashboardQuery returns the query of r, with defaults for the values
+// that are not given
+func parseDashboardQuery(r *http.Request) dashboardQuery {
+	values := r.URL.Query()
+	get := func(name string, value string) string {
+		if values.Get(name) != "" {
+			return values.Get(name)
+		}
+		return value
+	}
+
+	length, err := strconv.Atoi(get("length", ""))
+	if err != nil || length < 1 {
+		length = 30
+	}
+
+	return dashboardQuery{
+		Group:      get("group", "all"),
+		Repo:       get("repo", "all"),
+		Metric:     get("metric", "domain-data-added"),
+		Domain:     get("domain", "all"),
+		Aggregator: get("aggregator", ""),
+		TimeUnit:   get("unit", stats.TimeUnitDays),
+		TimeLength: length,
+		From:       get("from", ""),
+		To:         get("to", ""),
+		Format:     get("format", stats.ChartFormatSVG),
+	}
+}
+
+// renderChart renders the chart of query q with the stats in db
+func renderChart(db *stats.DB, q dashboardQuery) ([]byte, error) {
+	if err := stats.ValidateTimeUnit(q.TimeUnit); err != nil {
+		return nil, err
+	}
+	from, to, err := parseDateRange(q.From, q.To)
+	if err != nil {
+		return nil, err
+	}
+	if !from.IsZero() && to.IsZero() {
+		to = time.Now()
+	}
+	if !from.IsZero() && !from.Before(to) {
+		return nil, errors.New("from must be before to")
+	}
+
+	domain := wildcard(q.Domain, stats.AllDomains)
+	comparison, err := stats.NewComparison(
+		q.Metric,
+		[]string{wildcard(q.Repo, stats.AllRepos)},
+		wildcard(q.Group, stats.AllGroups),
+		[]string{domain},
+		q.TimeUnit,
+		q.Aggregator,
+	)
+	if err != nil {
+		return nil, err
+	}
+	comparison.SetGapFill(stats.GapFillCarry)
+	comparison.SetDateRange(from, to)
+	comparison.FetchDataFromDB(db, q.TimeUnit, q.TimeLength)
+
+	metric := comparison.Series[0].Metric
+	aggregator := metric.GetDefaultAggregator()
+	if q.Aggregator != "" {
+		if aggregator, err = newAggregatorForMetric(q.Aggregator, metric, q.Metric); err != nil {
+			return nil, err
+		}
+	}
+
+	var buffer bytes.Buffer
+	err = newChart(db, metric, aggregator, stats.DefaultAnomalyDeviation).Write(&buffer, q.Format)
+	return buffer.Bytes(), err
+}
+
+// chartContentTypes are the content types of the formats charts are served in
+var chartContentTypes = map[string]string{
+	stats.ChartFormatPNG: "image/png",
+	stats.ChartFormatSVG: "image/svg+xml",
+}
+
+// handleChart serves the chart of the query of the request as an image
+func (d *dashboard) handleChart(w http.ResponseWriter, r *http.Request) {
+	db, _ := d.current()
+	q := parseDashboardQuery(r)
+	image, err := renderChart(db, q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", chartContentTypes[q.Format])
+	w.Write(image)
+}
+
+// handleVersion serves the version of the stats currently loaded, which open
+// dashboards poll to refresh when the stats change
+func (d *dashboard) handleVersion(w http.ResponseWriter, r *http.Request) {
+	_, version := d.current()
+	fmt.Fprint(w, version)
+}
+
+// handleIndex serves the dashboard with the chart of the query of the request
+func (d *dashboard) handleIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	db, version := d.current()
+	q := parseDashboardQuery(r)
+	page := dashboardPage{
+		Query:       q,
+		Version:     version,
+		Groups:      []string{"all"},
+		Repos:       []string{"all"},
+		Domains:     []string{"all"},
+		Aggregators: []string{""},
+		TimeUnits:   stats.TimeUnits,
+		Formats:     []string{stats.ChartFormatSVG, stats.ChartFormatPNG},
+	}
+
+	for _, group := range groups.FetchGroups() {
+		page.Groups = append(page.Groups, group.Name)
+		page.Repos = append(page.Repos, group.Repos()...)
+		for _, domain := range group.Domains() {
+			page.Domains = append(page.Domains, domain.Name)
+		}
+	}
+	page.Repos = uniqueStrings(page.Repos)
+	page.Domains = uniqueStrings(page.Domains)
+
+	for name := range stats.Metrics {
+		page.Metrics = append(page.Metrics, name)
+	}
+	sort.Strings(page.Metrics)
+	for name := range stats.Aggregators {
+		page.Aggregators = append(page.Aggregators, name)
+	}
+	sort.Strings(page.Aggregators)
+
+	image, err := renderChart(db, q)
+	if err != nil {
+		page.Error = err.Error()
+	} else {
+		page.Chart = template.URL(fmt.Sprintf(
+			"data:%s;base64,%s",
+			chartContentTypes[q.Format],
+			base64.StdEncoding.EncodeToString(image),
+		))
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := dashboardTemplate.Execute(w, page); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// dashboardPage is the data the dashboard template is executed with
+type dashboardPage struct {
+	Query       dashboardQuery
+	Version     int
+	Groups      []string
+	Repos       []string
+	Metrics     []string
+	Domains     []string
+	Aggregators []string
+	TimeUnits   []string
+	Formats     []string
+	Chart       template.URL
+	Error       string
+}
+
+// selectField is a select of the form of the dashboard
+type selectField struct {
+	Name     string
+	Options  []string
+	Selected string
+}
+
+// dashboardTemplate is the html of the dashboard, the page is reloaded when the
+// version of the stats changes
+var dashboardTemplate = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"field": func(name string, options []string, selected string) selectField {
+		return selectField{Name: name, Options: options, Selected: selected}
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>dfb stats</title>
+<style>
+body { background: #424242; color: #fff; font-family: -apple-system, Helvetica, sans-serif; margin: 24px; }
+form { display: flex; flex-wrap: wrap; gap: 12px; align-items: flex-end; margin-bottom: 24px; }
+label { display: flex; flex-direction: column; font-size: 12px; gap: 4px; }
+select, input, button { font-size: 14px; padding: 4px; }
+img { width: 100%; max-width: 2048px; }
+.error { color: #eb5757; }
+</style>
+</head>
+<body>
+<form>
+{{define "select"}}<select name="{{.Name}}" onchange="this.form.submit()">{{range .Options}}<option value="{{.}}"{{if eq . $.Selected}} selected{{end}}>{{if .}}{{.}}{{else}}default{{end}}</option>{{end}}</select>{{end}}
+<label>group {{template "select" (field "group" .Groups .Query.Group)}}</label>
+<label>repo {{template "select" (field "repo" .Repos .Query.Repo)}}</label>
+<label>metric {{template "select" (field "metric" .Metrics .Query.Metric)}}</label>
+<label>domain {{template "select" (field "domain" .Domains .Query.Domain)}}</label>
+<label>aggregator {{template "select" (field "aggregator" .Aggregators .Query.Aggregator)}}</label>
+<label>time unit {{template "select" (field "unit" .TimeUnits .Query.TimeUnit)}}</label>
+<label>time length <input type="number" name="length" min="1" value="{{.Query.TimeLength}}"></label>
+<label>from <input type="date" name="from" value="{{.Query.From}}"></label>
+<label>to <input type="date" name="to" value="{{.Query.To}}"></label>
+<label>format {{template "select" (field "format" .Formats .Query.Format)}}</label>
+<button type="submit">draw</button>
+</form>
+{{if .Error}}<p class="error">{{.Error}}</p>{{else}}<img src="{{.Chart}}" alt="chart">{{end}}
+<script>
+setInterval(function () {
+	fetch("/version").then(function (r) { return r.text() }).then(function (version) {
+		if (version !== "{{.Version}}") { location.reload() }
+	})
+}, 3000)
+</script>
+</body>
+</html>
+`))
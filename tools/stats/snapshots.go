@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nattvara/dfb/internal/groups"
+	"github.com/nattvara/dfb/internal/restic"
+	"github.com/nattvara/dfb/internal/stats"
+
+	"github.com/spf13/cobra"
+)
+
+var snapshotsFormat string
+
+var snapshotsFrom string
+
+var snapshotsTo string
+
+var snapshotsCmd = &cobra.Command{
+	Use:   "snapshots [group] [repo] [domain]",
+	Short: "List the snapshots of a group in a repo",
+	Long: `The snapshots command lists the snapshots of a group in a repo, optionally
+only the snapshots of a single domain. The password of the repo is read from stdin.
+Snapshots are joined with the stats recorded during backup where available`,
+	Args: cobra.RangeArgs(2, 3),
+	Run: func(cmd *cobra.Command, args []string) {
+		group := groups.Load(args[0])
+		repoName := args[1]
+		var tags []string
+		if len(args) == 3 {
+			tags = append(tags, args[2])
+		}
+
+		from, to, err := parseDateRange(snapshotsFrom, snapshotsTo)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		repoPath, err := group.RepoPath(repoName)
+		if err != nil {
+			fmt.Println("unknown repo " + repoName)
+			os.Exit(1)
+		}
+
+		client := restic.NewClient(repoPath, &restic.ReaderPassword{Reader: os.Stdin})
+		snapshots, err := client.Snapshots(tags...)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		db := stats.NewDB()
+		db.Load(group.Name)
+
+		rows := []snapshotRow{}
+		for _, snapshot := range snapshots {
+			if !from.IsZero() && snapshot.Time.Before(from) {
+				continue
+			}
+			if !to.IsZero() && !snapshot.Time.Before(to) {
+				continue
+			}
+			rows = append(rows, newSnapshotRow(snapshot, db.GetSnapshotSummary(snapshot.ID)))
+		}
+
+		switch snapshotsFormat {
+		case "table":
+			printSnapshotsTable(rows)
+		case "json":
+			printJSON(rows)
+		default:
+			fmt.Println("unknown format " + snapshotsFormat)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	snapshotsCmd.Flags().StringVarP(&snapshotsFormat, "format", "f", "table", "output format, table or json")
+	snapshotsCmd.Flags().StringVarP(&snapshotsFrom, "from", "", "", "only list snapshots taken on or after date (YYYY-MM-DD)")
+	snapshotsCmd.Flags().StringVarP(&snapshotsTo, "to", "", "", "only list snapshots taken on or before date (YYYY-MM-DD)")
+}
+
+// snapshotRow is a snapshot listed by the snapshots command
+type snapshotRow struct {
+	ID             string    `json:"id"`
+	ShortID        string    `json:"short_id"`
+	Time           time.Time `json:"time"`
+	Host           string    `json:"host"`
+	Domains        []string  `json:"domains"`
+	Paths          []string  `json:"paths"`
+	DataAdded      *int      `json:"data_added,omitempty"`
+	BytesProcessed *int      `json:"total_bytes_processed,omitempty"`
+	Duration       *float64  `json:"total_duration,omitempty"`
+}
+
+// newSnapshotRow returns a snapshotRow for snapshot, with values from summary if not nil
+func newSnapshotRow(snapshot restic.Snapshot, summary *stats.SnapshotSummary) snapshotRow {
+	row := snapshotRow{
+		ID:      snapshot.ID,
+		ShortID: snapshot.ShortID,
+		Time:    snapshot.Time,
+		Host:    snapshot.Hostname,
+		Domains: snapshot.Tags,
+		Paths:   snapshot.Paths,
+	}
+	if summary != nil {
+		row.DataAdded = &summary.DataAdded
+		row.BytesProcessed = &summary.TotalBytesProcessed
+		row.Duration = &summary.TotalDuration
+	}
+	return row
+}
+
+// printSnapshotsTable prints given rows as a table
+func printSnapshotsTable(rows []snapshotRow) {
+	bytes := &stats.BytesFormatter{}
+	duration := &stats.TimeFormatter{}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTIME\tHOST\tDOMAIN\tPATHS\tPROCESSED\tADDED\tDURATION")
+	for _, row := range rows {
+		processed, added, took := "-", "-", "-"
+		if row.DataAdded != nil {
+			processed = bytes.Format(float64(*row.BytesProcessed))
+			added = bytes.Format(float64(*row.DataAdded))
+			took = duration.Format(*row.Duration)
+		}
+		fmt.Fprintf(
+			w,
+			"%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			row.ShortID,
+			row.Time.Local().Format("2006-01-02 15:04:05"),
+			row.Host,
+			strings.Join(row.Domains, ","),
+			strings.Join(row.Paths, ","),
+			processed,
+			added,
+			took,
+		)
+	}
+	w.Flush()
+	fmt.Printf("\n%v snapshots\n", len(rows))
+}
+
+// printJSON prints v as indented json
+func printJSON(v interface{}) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	fmt.Println(string(data))
+}
+
+// parseDateRange parses from and to dates (YYYY-MM-DD) in local time, as given
+// to the --from and --to flags, an empty string gives a zero time. The returned
+// to is the start of the day after, so that the whole to date is included
+func parseDateRange(from string, to string) (time.Time, time.Time, error) {
+	var start, end time.Time
+	var err error
+
+	if from != "" {
+		if start, err = time.ParseInLocation("2006-01-02", from, time.Local); err != nil {
+			return start, end, fmt.Errorf("invalid date %s, expected YYYY-MM-DD", from)
+		}
+	}
+	if to != "" {
+		if end, err = time.ParseInLocation("2006-01-02", to, time.Local); err != nil {
+			return start, end, fmt.Errorf("invalid date %s, expected YYYY-MM-DD", to)
+		}
+		end = end.AddDate(0, 0, 1)
+	}
+	return start, end, nil
+}
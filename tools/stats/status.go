@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nattvara/dfb/internal/groups"
+	"github.com/nattvara/dfb/internal/stats"
+
+	tm "github.com/buger/goterm"
+	"github.com/spf13/cobra"
+)
+
+// statusExitUnknown is the exit code of the status command when the status
+// could not be checked, following the convention of monitoring plugins
+const statusExitUnknown = 3
+
+var statusFormat string
+
+var statusWarning string
+
+var statusCritical string
+
+var statusCmd = &cobra.Command{
+	Use:   "status [group|all]",
+	Short: "Report how long ago each domain was backed up",
+	Long: `The status command reports the age of the last snapshot of every domain of a
+group in every repo of the group, and whether it is older than the warning or
+critical threshold of the domain. Thresholds can be set per domain in
+~/.dfb/[group]/thresholds. The exit code is the worst state, 0 if all domains
+are ok, 1 for warning, 2 for critical and 3 if the status could not be checked`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		var loaded []groups.Group
+		if args[0] == "all" {
+			loaded = groups.FetchGroups()
+		} else {
+			loaded = append(loaded, groups.Load(args[0]))
+			if _, err := os.Stat(loaded[0].Path); err != nil {
+				fmt.Println("no group named " + args[0])
+				os.Exit(statusExitUnknown)
+			}
+		}
+
+		db := stats.NewDB()
+		now := time.Now()
+		state := stats.StateOK
+		rows := []statusRow{}
+		for _, group := range loaded {
+			thresholds, err := loadStatusThresholds(group.Name)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(statusExitUnknown)
+			}
+
+			var domains []string
+			for _, domain := range group.Domains() {
+				domains = append(domains, domain.Name)
+			}
+
+			if err := loadStatusStats(db, group.Name); err != nil {
+				fmt.Println(err)
+				os.Exit(statusExitUnknown)
+			}
+			for _, f := range stats.CheckFreshness(db, group.Name, group.Repos(), domains, thresholds, now) {
+				rows = append(rows, newStatusRow(f))
+				state = stats.WorseState(state, f.State)
+			}
+		}
+
+		switch statusFormat {
+		case formatTable:
+			printStatusTable(rows)
+		case stats.ExportFormatJSON:
+			printJSON(statusReport{State: state, Domains: rows})
+		default:
+			fmt.Println("unknown format " + statusFormat)
+			os.Exit(statusExitUnknown)
+		}
+
+		os.Exit(stats.StateExitCode(state))
+	},
+}
+
+func init() {
+	statusCmd.Flags().StringVarP(&statusFormat, "format", "f", formatTable, "output format, table or json")
+	statusCmd.Flags().StringVarP(&statusWarning, "warning", "w", "", "age of the last snapshot that is a warning, eg. 36h or 2d, for domains without a threshold of their own")
+	statusCmd.Flags().StringVarP(&statusCritical, "critical", "c", "", "age of the last snapshot that is critical, eg. 7d or 2w, for domains without a threshold of their own")
+}
+
+// loadStatusThresholds loads the thresholds of group with given name, with the
+// default replaced by the --warning and --critical flags if given
+func loadStatusThresholds(groupName string) (*stats.Thresholds, error) {
+	thresholds, err := stats.LoadThresholds(groupName)
+	if err != nil {
+		return nil, err
+	}
+
+	warning, critical := statusWarning, statusCritical
+	if warning == "" && critical == "" {
+		return thresholds, nil
+	}
+	if warning == "" {
+		warning = thresholds.Default.Warning.String()
+	}
+	if critical == "" {
+		critical = thresholds.Default.Critical.String()
+	}
+	if thresholds.Default, err = stats.ParseThreshold(warning, critical); err != nil {
+		return nil, err
+	}
+	return thresholds, nil
+}
+
+// loadStatusStats loads the stats of group with given name into db, stats files
+// that cannot be read are an error rather than a panic, so that the status is
+// unknown instead of critical
+func loadStatusStats(db *stats.DB, groupName string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("failed to load stats of group %s. %v", groupName, r)
+		}
+	}()
+	db.Load(groupName)
+	return nil
+}
+
+// statusReport is the report of the status command as printed in json
+type statusReport struct {
+	State   string      `json:"state"`
+	Domains []statusRow `json:"domains"`
+}
+
+// statusRow is the status of a domain in a repo as listed by the status command,
+// LastSnapshot and Age are nil if the domain was never backed up to the repo
+type statusRow struct {
+	Group           string     `json:"group"`
+	Repo            string     `json:"repo"`
+	Domain          string     `json:"domain"`
+	LastSnapshot    *time.Time `json:"last_snapshot"`
+	Age             *float64   `json:"age_seconds"`
+	WarningSeconds  float64    `json:"warning_seconds"`
+	CriticalSeconds float64    `json:"critical_seconds"`
+	State           string     `json:"state"`
+}
+
+// newStatusRow returns a statusRow for f
+func newStatusRow(f stats.Freshness) statusRow {
+	row := statusRow{
+		Group:           f.Group,
+		Repo:            f.Repo,
+		Domain:          f.Domain,
+		WarningSeconds:  f.Threshold.Warning.Seconds(),
+		CriticalSeconds: f.Threshold.Critical.Seconds(),
+		State:           f.State,
+	}
+	if !f.LastSnapshot.IsZero() {
+		age := f.Age.Seconds()
+		row.LastSnapshot = &f.LastSnapshot
+		row.Age = &age
+	}
+	return row
+}
+
+// statusColors are the colors of the states in the table of the status command
+var statusColors = map[string]int{
+	stats.StateOK:       tm.GREEN,
+	stats.StateWarning:  tm.YELLOW,
+	stats.StateCritical: tm.RED,
+}
+
+// printStatusTable prints given rows as a table, the states are colored if
+// stdout is a terminal
+func printStatusTable(rows []statusRow) {
+	colored := isTerminal(os.Stdout)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "GROUP\tREPO\tDOMAIN\tLAST SNAPSHOT\tAGE\tSTATE")
+	for _, row := range rows {
+		last, age := "never", "-"
+		if row.LastSnapshot != nil {
+			last = row.LastSnapshot.Local().Format("2006-01-02 15:04")
+			age = stats.FormatAge(time.Duration(*row.Age * float64(time.Second)))
+		}
+		state := row.State
+		if colored {
+			state = tm.Color(state, statusColors[state])
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", row.Group, row.Repo, row.Domain, last, age, state)
+	}
+	w.Flush()
+}
+
+// isTerminal returns whether file is a terminal rather than a file or pipe
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/nattvara/dfb/internal/stats"
+
+	tm "github.com/buger/goterm"
+	"github.com/spf13/cobra"
+)
+
+var topLimit int
+
+var topFrom string
+
+var topFormat string
+
+var topAggregator string
+
+var topCmd = &cobra.Command{
+	Use:   "top [group|all] [repo|all] [metric]",
+	Short: "Rank the domains of a group by a metric",
+	Long: `The top command aggregates a metric for each domain of a group over all
+values recorded since the --since date, and lists the domains with the largest values
+first. Only metrics that support domains can be ranked. By default the
+values since the start of the current month are used`,
+	Args: cobra.ExactArgs(3),
+	Run: func(cmd *cobra.Command, args []string) {
+		groupName := wildcard(args[0], stats.AllGroups)
+		repoName := wildcard(args[1], stats.AllRepos)
+		metricName := args[2]
+
+		now := time.Now()
+		since := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+		if topFrom != "" {
+			var err error
+			if since, _, err = parseDateRange(topFrom, ""); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+		}
+
+		domains := groupDomains(groupName)
+		if len(domains) == 0 {
+			fmt.Println("group " + args[0] + " has no domains")
+			os.Exit(1)
+		}
+
+		db := stats.NewDB()
+		db.Load(groupName)
+
+		ranking, err := stats.NewRanking(
+			db,
+			metricName,
+			repoName,
+			groupName,
+			domains,
+			since,
+			topAggregator,
+			topLimit,
+		)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		switch topFormat {
+		case formatTable:
+			err = ranking.WriteTable(os.Stdout)
+		case formatTerm:
+			err = ranking.WriteBars(os.Stdout, tm.Width())
+		case stats.ExportFormatJSON:
+			printJSON(ranking.Domains)
+		default:
+			fmt.Println("unknown format " + topFormat)
+			os.Exit(1)
+		}
+
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	topCmd.Flags().IntVarP(&topLimit, "limit", "n", 10, "how many domains to list, 0 lists all")
+	topCmd.Flags().StringVarP(&topFrom, "since", "", "", "rank by the values recorded on or after date (YYYY-MM-DD), defaults to the start of the month")
+	topCmd.Flags().StringVarP(&topFrom, "from", "", "", "same as --since")
+	topCmd.Flags().StringVarP(&topFormat, "format", "f", formatTerm, "output format, term, table or json")
+	topCmd.Flags().StringVarP(&topAggregator, "aggregator", "a", "", "aggregation method to use for the values of each domain")
+}